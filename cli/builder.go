@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
 
 	"github.com/urfave/cli/v3"
 	c "github.com/vekio/config"
@@ -11,6 +15,37 @@ import (
 type CLIConfig[T c.Validatable] struct {
 	ConfigFile *c.ConfigFile[T]
 	Command    *cli.Command
+
+	initMode    InitMode
+	initialized bool
+}
+
+// InitMode controls how the configuration file is initialized before the
+// conf subcommands run.
+type InitMode int
+
+const (
+	// InitCreate creates the file with the defaults when it does not exist.
+	InitCreate InitMode = iota
+	// InitFailIfMissing reports an error when the file does not exist.
+	InitFailIfMissing
+	// InitReadOnly loads the file when it exists and otherwise uses the
+	// defaults, without creating anything on disk.
+	InitReadOnly
+)
+
+// CLIOption customizes a CLIConfig during construction.
+type CLIOption[T c.Validatable] func(*CLIConfig[T])
+
+// WithInitMode selects how the configuration file is initialized. The
+// default is InitCreate.
+func WithInitMode[T c.Validatable](mode InitMode) CLIOption[T] {
+	return func(c *CLIConfig[T]) {
+		if c == nil {
+			return
+		}
+		c.initMode = mode
+	}
 }
 
 // AddSubcommands appends additional CLI commands under the "conf" namespace.
@@ -27,31 +62,124 @@ func (c *CLIConfig[T]) AddSubcommands(commands ...*cli.Command) {
 	}
 }
 
-// NewCLIConfig builds a CLI configuration helper for the provided application.
-// When configFile is nil a default config file is built using the supplied
-// options. The application name defaults to the executable name but can be
-// overridden with config.WithAppName.
-func NewCLIConfig[T c.Validatable](configFile *c.ConfigFile[T]) (*CLIConfig[T], error) {
+// NewCLIConfig builds a CLI configuration helper for the provided configuration
+// file. Nothing is read or written until a conf subcommand runs: the file is
+// initialized lazily by Before, which is installed on the conf command and can
+// also be set as the application's Before hook.
+func NewCLIConfig[T c.Validatable](configFile *c.ConfigFile[T], options ...CLIOption[T]) (*CLIConfig[T], error) {
 	if configFile == nil {
-		return nil, fmt.Errorf("")
+		return nil, fmt.Errorf("config cli: configFile must not be nil; build one with config.NewYAMLConfigFile or config.NewJSONConfigFile")
 	}
 
-	if err := configFile.SoftInit(); err != nil {
-		return nil, err
+	cfg := &CLIConfig[T]{
+		ConfigFile: configFile,
+		Command:    newCmdConfig(configFile),
 	}
+	for _, option := range options {
+		if option != nil {
+			option(cfg)
+		}
+	}
+	cfg.Command.Before = cfg.Before
+	return cfg, nil
+}
 
-	cmd := newCmdConfig(configFile)
+// Before initializes the configuration according to the init mode: it
+// switches to the file given with --config or the profile given with
+// --profile, loads the file, and applies the generated flags. It runs once;
+// later calls are no-ops. The conf init, recover, sign, and verify
+// subcommands only select the file, and so do the conf profile subcommands
+// when the selected profile does not exist, so users can switch away from it.
+func (c *CLIConfig[T]) Before(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	if c.initialized {
+		return ctx, nil
+	}
+	if err := selectConfigFile(cmd, c.ConfigFile); err != nil {
+		return ctx, err
+	}
+	selectProfile(cmd, c.ConfigFile)
+	subcommand := c.subcommand(cmd)
+	if slices.Contains([]string{"init", "recover", "sign", "verify"}, subcommand) {
+		return ctx, nil
+	}
 
-	return &CLIConfig[T]{
-		ConfigFile: configFile,
-		Command:    cmd,
-	}, nil
+	if err := c.load(); err != nil {
+		if subcommand == "profile" && isProfileNotFound(err) {
+			return ctx, nil
+		}
+		return ctx, err
+	}
+	if err := c.ApplyFlags(cmd); err != nil {
+		return ctx, err
+	}
+	c.initialized = true
+	return ctx, nil
+}
+
+// load reads the configuration file as selected by the init mode. Parse
+// errors include a snippet of the offending line.
+func (c *CLIConfig[T]) load() error {
+	switch c.initMode {
+	case InitFailIfMissing:
+		err := c.ConfigFile.Reload()
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w; run \"%s init\" to create it", err, c.Command.Name)
+		}
+		return withSnippet(err, c.ConfigFile.RedactChanges)
+	case InitReadOnly:
+		return withSnippet(c.ConfigFile.Load(), c.ConfigFile.RedactChanges)
+	default:
+		return withSnippet(c.ConfigFile.SoftInit(), c.ConfigFile.RedactChanges)
+	}
+}
+
+// subcommand returns the name of the conf subcommand cmd is about to run, or
+// an empty string. Some of them must not load the file: init, which must not
+// find it already created, recover, which must work while it is corrupt, and
+// sign and verify, which must work while its signature is missing or
+// invalid.
+func (c *CLIConfig[T]) subcommand(cmd *cli.Command) string {
+	args := cmd.Args().Slice()
+	if cmd != c.Command {
+		i := slices.Index(args, c.Command.Name)
+		if i < 0 {
+			return ""
+		}
+		args = args[i+1:]
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
 }
 
-// Attach registers the configuration command into the provided CLI application.
+// Attach registers the configuration command into the provided CLI application,
+// together with the global --config and --profile flags unless the application
+// already defines flags with those names. Aliases of those flags that the
+// application already uses, such as -c, are left out.
 func (c *CLIConfig[T]) Attach(app *cli.Command) {
 	if c == nil || app == nil || c.Command == nil {
 		return
 	}
 	app.Commands = append(app.Commands, c.Command)
+
+	for _, flag := range []*cli.StringFlag{newConfigFlag(c.ConfigFile.AppName()), newProfileFlag()} {
+		if hasFlag(app, flag.Name) {
+			continue
+		}
+		flag.Aliases = slices.DeleteFunc(flag.Aliases, func(alias string) bool {
+			return hasFlag(app, alias)
+		})
+		app.Flags = append(app.Flags, flag)
+	}
+}
+
+// hasFlag reports whether cmd defines a flag called name.
+func hasFlag(cmd *cli.Command, name string) bool {
+	for _, flag := range cmd.Flags {
+		if slices.Contains(flag.Names(), name) {
+			return true
+		}
+	}
+	return false
 }
@@ -1,23 +1,55 @@
 package cli
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/urfave/cli/v3"
 	c "github.com/vekio/config"
 )
 
 // newCmdConfig wires the configuration management namespace with the
-// subcommands that operate on the application's config file.
+// subcommands that operate on the application's config file. Subcommands
+// that write the file are hidden when the configuration is read-only.
 func newCmdConfig[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
 	cmd := &cli.Command{
 		Name:        "conf",
 		Usage:       "Manage application's configuration file.",
 		UsageText:   "conf [command]",
-		Description: "Provides helper commands to show, edit, and validate the configuration file managed by this application.",
+		Description: "Provides helper commands to show, list, set, edit, validate, lint, diff, initialize, reset, roll back, recover, encrypt, sign, and verify the configuration file and its profiles managed by this application.",
 		Commands: []*cli.Command{
 			newCmdShow(config),
-			newCmdEdit(config),
+			newCmdList(config),
+			writable(config, newCmdSet(config)),
+			writable(config, newCmdEdit(config)),
 			newCmdValidate(config),
+			newCmdLint(config),
+			newCmdDiff(config),
+			writable(config, newCmdInit(config)),
+			writable(config, newCmdReset(config)),
+			newCmdHistory(config),
+			writable(config, newCmdRollback(config)),
+			writable(config, newCmdEncrypt(config)),
+			writable(config, newCmdDecrypt(config)),
+			writable(config, newCmdRotateKey(config)),
+			writable(config, newCmdSign(config)),
+			newCmdVerify(config),
+			newCmdProfile(config),
+			newCmdRecover(config),
 		},
 	}
 	return cmd
 }
+
+// writable hides cmd and makes it fail with config.ErrReadOnly when config
+// is read-only, so the help output only lists what can actually run.
+func writable[T c.Validatable](config *c.ConfigFile[T], cmd *cli.Command) *cli.Command {
+	if !config.ReadOnly() {
+		return cmd
+	}
+	cmd.Hidden = true
+	cmd.Action = func(context.Context, *cli.Command) error {
+		return fmt.Errorf("conf %s: %w", cmd.Name, c.ErrReadOnly)
+	}
+	return cmd
+}
@@ -0,0 +1,439 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+type testSettings struct {
+	Name     string   `json:"name"`
+	Port     int      `json:"port"`
+	Password c.Secret `json:"password"`
+}
+
+func (testSettings) Validate() error { return nil }
+
+func TestShow(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir())
+	writeTestFile(t, config.Path(), `{"name":"main","password":"hunter2"}`)
+	writeTestFile(t, filepath.Join(config.DropInDir(), "10-port.json"), `{"port":8080}`)
+
+	tests := []struct {
+		args     []string
+		contains []string
+		excludes []string
+	}{
+		{
+			args:     []string{"show"},
+			contains: []string{`"name":"main"`, `"password":"[REDACTED]"`},
+			excludes: []string{"hunter2", "8080"},
+		},
+		{
+			args:     []string{"show", "--effective"},
+			contains: []string{`"name":"main"`, `"port":8080`, `"password":"[REDACTED]"`},
+			excludes: []string{"hunter2"},
+		},
+		{
+			args:     []string{"show", "--reveal"},
+			contains: []string{`"name":"main"`, `"password":"hunter2"`},
+			excludes: []string{"8080", "[REDACTED]"},
+		},
+		{
+			args:     []string{"show", "--reveal", "--effective"},
+			contains: []string{`"name":"main"`, `"port":8080`, `"password":"hunter2"`},
+			excludes: []string{"[REDACTED]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			out, err := runConf(t, config, tt.args...)
+			if err != nil {
+				t.Fatalf("conf %s failed: %v", strings.Join(tt.args, " "), err)
+			}
+			out = compactJSON(t, out)
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected output to contain %s, got %s", want, out)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("expected output not to contain %s, got %s", unwanted, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir(), c.WithSecretKey[testSettings](bytes.Repeat([]byte("k"), 32)))
+
+	out, err := runConf(t, config, "set", "password", "hunter2")
+	if err != nil {
+		t.Fatalf("conf set failed: %v", err)
+	}
+	if want := `password = "[REDACTED]"`; strings.TrimSpace(out) != want {
+		t.Fatalf("expected %s, got %s", want, out)
+	}
+	if got := config.Data().Password.Reveal(); got != "hunter2" {
+		t.Fatalf("expected the password to be set, got %q", got)
+	}
+
+	out, err = runConf(t, config, "set", "name", "main")
+	if err != nil {
+		t.Fatalf("conf set failed: %v", err)
+	}
+	if want := `name = "main"`; strings.TrimSpace(out) != want {
+		t.Fatalf("expected %s, got %s", want, out)
+	}
+}
+
+func TestParseFieldValueRange(t *testing.T) {
+	tests := []struct {
+		value any
+		raw   string
+	}{
+		{int8(0), "300"},
+		{int16(0), "-40000"},
+		{uint8(0), "256"},
+		{uint32(0), "4294967296"},
+		{float32(0), "1e40"},
+	}
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.value)
+		if _, err := parseFieldValue(typ, []string{tt.raw}); err == nil {
+			t.Errorf("expected %s to be out of range for %s", tt.raw, typ)
+		}
+	}
+
+	value, err := parseFieldValue(reflect.TypeOf(int8(0)), []string{"-128"})
+	if err != nil || value != int64(-128) {
+		t.Fatalf("expected -128 to fit in int8, got %v, %v", value, err)
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	other := filepath.Join(t.TempDir(), "other.json")
+	writeTestFile(t, other, `{"name":"other"}`)
+
+	t.Run("flag", func(t *testing.T) {
+		config := mustNewTestConfigFile(t, t.TempDir())
+		out, err := runApp(t, config, &cli.Command{Name: "testapp"}, "--config", other, "conf", "show")
+		if err != nil {
+			t.Fatalf("conf show failed: %v", err)
+		}
+		if want := `{"name":"other"}`; compactJSON(t, out) != want {
+			t.Fatalf("expected %s, got %s", want, out)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("TESTAPP_CONFIG", other)
+		config := mustNewTestConfigFile(t, t.TempDir())
+		out, err := runConf(t, config, "show")
+		if err != nil {
+			t.Fatalf("conf show failed: %v", err)
+		}
+		if want := `{"name":"other"}`; compactJSON(t, out) != want {
+			t.Fatalf("expected %s, got %s", want, out)
+		}
+	})
+
+	t.Run("alias taken by the application", func(t *testing.T) {
+		config := mustNewTestConfigFile(t, t.TempDir())
+		app := &cli.Command{
+			Name:  "testapp",
+			Flags: []cli.Flag{&cli.BoolFlag{Name: "color", Aliases: []string{"c"}}},
+		}
+		out, err := runApp(t, config, app, "-c", "--config", other, "conf", "show")
+		if err != nil {
+			t.Fatalf("conf show failed: %v", err)
+		}
+		if want := `{"name":"other"}`; compactJSON(t, out) != want {
+			t.Fatalf("expected %s, got %s", want, out)
+		}
+		if !app.Bool("color") {
+			t.Fatal("expected -c to set the application's flag")
+		}
+		for _, flag := range app.Flags {
+			if names := flag.Names(); names[0] == "config" && !slices.Equal(names, []string{"config"}) {
+				t.Fatalf("expected --config without the -c alias, got %v", names)
+			}
+		}
+	})
+}
+
+type flagSettings struct {
+	Config  string `json:"config"`
+	Profile string `json:"profile"`
+	Server  struct {
+		Host string `json:"host" doc:"address to listen on"`
+		Port int    `json:"port"`
+	} `json:"server"`
+	Tags []string `json:"tags"`
+}
+
+func (flagSettings) Validate() error { return nil }
+
+func TestFlags(t *testing.T) {
+	dir := t.TempDir()
+	config, err := c.NewJSONConfigFile(
+		c.WithPath[flagSettings](dir),
+		c.WithAppName[flagSettings]("testapp"),
+	)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	other := filepath.Join(dir, "other.json")
+	writeTestFile(t, other, `{"config":"file","profile":"file","server":{"host":"localhost","port":1}}`)
+
+	cliConfig, err := NewCLIConfig(config)
+	if err != nil {
+		t.Fatalf("NewCLIConfig failed: %v", err)
+	}
+	flags := cliConfig.Flags()
+	var names []string
+	for _, flag := range flags {
+		names = append(names, flag.Names()...)
+	}
+	if want := []string{"server-host", "server-port", "tags"}; !slices.Equal(names, want) {
+		t.Fatalf("expected flags %v, got %v", want, names)
+	}
+
+	var got flagSettings
+	app := &cli.Command{
+		Name:   "testapp",
+		Flags:  flags,
+		Before: cliConfig.Before,
+		Action: func(context.Context, *cli.Command) error {
+			got = config.Data()
+			return nil
+		},
+	}
+	cliConfig.Attach(app)
+	t.Setenv("TESTAPP_SERVER_HOST", "example.com")
+	args := []string{"testapp", "--config", other, "--server-port", "8080", "--tags", "a", "--tags", "b"}
+	if err := app.Run(context.Background(), args); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := flagSettings{Config: "file", Profile: "file", Tags: []string{"a", "b"}}
+	want.Server.Host, want.Server.Port = "example.com", 8080
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestLazyInit(t *testing.T) {
+	tests := [][]string{
+		{"--help"},
+		{"conf", "--help"},
+		{"conf", "recover"},
+	}
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			config := mustNewTestConfigFile(t, t.TempDir())
+			if _, err := runApp(t, config, &cli.Command{Name: "testapp"}, args...); err != nil {
+				t.Fatalf("testapp %s failed: %v", strings.Join(args, " "), err)
+			}
+			if _, err := os.Stat(config.Path()); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected no configuration file, got %v", err)
+			}
+		})
+	}
+
+	t.Run("conf init", func(t *testing.T) {
+		config := mustNewTestConfigFile(t, t.TempDir())
+		out, err := runConf(t, config, "init")
+		if err != nil {
+			t.Fatalf("conf init failed: %v", err)
+		}
+		if !strings.Contains(out, "configuration file created") {
+			t.Fatalf("expected init to create the file itself, got %s", out)
+		}
+	})
+}
+
+func TestRecoverShow(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir())
+	writeTestFile(t, config.Path()+".corrupt-20260101T000000.000000000", "{\n  \"name\": \"main\",\n  \"password\": \"hunter2\",\n")
+
+	out, err := runConf(t, config, "recover", "show")
+	if err != nil {
+		t.Fatalf("conf recover show failed: %v", err)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, `"password": "[REDACTED]"`) || !strings.Contains(out, `"name": "main"`) {
+		t.Fatalf("expected the password to be masked, got %s", out)
+	}
+
+	out, err = runConf(t, config, "recover", "show", "--reveal")
+	if err != nil {
+		t.Fatalf("conf recover show --reveal failed: %v", err)
+	}
+	if !strings.Contains(out, `"password": "hunter2"`) {
+		t.Fatalf("expected the raw file, got %s", out)
+	}
+}
+
+func TestProfileCommandsWithMissingProfile(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir())
+	writeTestFile(t, config.Path(), `{"name":"main"}`)
+	writeTestFile(t, config.ProfilePath("dev"), `{"name":"dev"}`)
+	t.Setenv("TESTAPP_PROFILE", "gone")
+
+	if _, err := runConf(t, config, "show"); !errors.Is(err, c.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	out, err := runConf(t, config, "profile", "list")
+	if err != nil {
+		t.Fatalf("conf profile list failed: %v", err)
+	}
+	if !strings.Contains(out, "dev") {
+		t.Fatalf("expected profiles to be listed, got %q", out)
+	}
+	if _, err := runConf(t, config, "profile", "delete", "--yes", "dev"); err != nil {
+		t.Fatalf("conf profile delete failed: %v", err)
+	}
+}
+
+func TestProfileUseWithMissingProfile(t *testing.T) {
+	dir := t.TempDir()
+	config := mustNewTestConfigFile(t, dir)
+	writeTestFile(t, config.Path(), `{"name":"main"}`)
+	writeTestFile(t, config.ProfilePath("dev"), `{"name":"dev"}`)
+	if err := config.UseProfile("dev"); err != nil {
+		t.Fatalf("UseProfile failed: %v", err)
+	}
+	if err := os.Remove(config.ProfilePath("dev")); err != nil {
+		t.Fatalf("remove profile: %v", err)
+	}
+
+	config = mustNewTestConfigFile(t, dir)
+	if _, err := runConf(t, config, "show"); !errors.Is(err, c.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if _, err := runConf(t, config, "profile", "use", c.DefaultProfile); err != nil {
+		t.Fatalf("conf profile use failed: %v", err)
+	}
+
+	config = mustNewTestConfigFile(t, dir)
+	if _, err := runConf(t, config, "show"); err != nil {
+		t.Fatalf("conf show failed after switching profile: %v", err)
+	}
+}
+
+func mustNewTestConfigFile(t *testing.T, dir string, options ...c.ConfigFileOption[testSettings]) *c.ConfigFile[testSettings] {
+	t.Helper()
+	options = append([]c.ConfigFileOption[testSettings]{
+		c.WithPath[testSettings](dir),
+		c.WithAppName[testSettings]("testapp"),
+	}, options...)
+	config, err := c.NewJSONConfigFile(options...)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	return config
+}
+
+// runConf runs the conf subcommand with args on a fresh CLI for config and
+// returns what it printed.
+func runConf[T c.Validatable](t *testing.T, config *c.ConfigFile[T], args ...string) (string, error) {
+	t.Helper()
+	return runApp(t, config, &cli.Command{Name: "testapp"}, append([]string{"conf"}, args...)...)
+}
+
+// runApp attaches the configuration command to app and runs it with args,
+// which may start with global flags, and returns what it printed.
+func runApp[T c.Validatable](t *testing.T, config *c.ConfigFile[T], app *cli.Command, args ...string) (string, error) {
+	t.Helper()
+	cliConfig, err := NewCLIConfig(config)
+	if err != nil {
+		t.Fatalf("NewCLIConfig failed: %v", err)
+	}
+	var out bytes.Buffer
+	cliConfig.Attach(app)
+	setWriter(app, &out)
+	err = app.Run(context.Background(), append([]string{app.Name}, args...))
+	return out.String(), err
+}
+
+// setWriter sends the output of cmd and its subcommands to w.
+func setWriter(cmd *cli.Command, w io.Writer) {
+	cmd.Writer, cmd.ErrWriter = w, w
+	for _, sub := range cmd.Commands {
+		setWriter(sub, w)
+	}
+}
+
+func compactJSON(t *testing.T, out string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(out)); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	return buf.String()
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir())
+	writeTestFile(t, config.Path(), "{\n\t\"name\": \"main\",\n\t\"port\": }\n")
+
+	_, err := runConf(t, config, "list")
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	var perr *c.ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParseError, got %v", err)
+	}
+	want := " 3 | \t\"port\": }\n   | \t        ^"
+	if !strings.HasSuffix(err.Error(), want) {
+		t.Fatalf("expected the message to end with\n%s\ngot\n%s", want, err)
+	}
+	if got := FormatError(perr); !strings.HasSuffix(got, want) {
+		t.Fatalf("expected FormatError to render the snippet, got\n%s", got)
+	}
+}
+
+func TestFormatConflictError(t *testing.T) {
+	config := mustNewTestConfigFile(t, t.TempDir())
+	err := &c.ConflictError{
+		Path:  config.Path(),
+		Local: []c.Change{{Key: "password", Kind: c.ChangeModified, Old: "old-secret", New: "new-secret"}},
+		Disk:  []c.Change{{Key: "name", Kind: c.ChangeModified, Old: "a", New: "b"}},
+	}
+
+	msg := withSnippet(err, config.RedactChanges).Error()
+	if strings.Contains(msg, "secret") {
+		t.Fatalf("expected the password to be masked, got:\n%s", msg)
+	}
+	if !strings.Contains(msg, `~ name: "a" -> "b"`) || !strings.Contains(msg, `~ password: "[REDACTED]" -> "[REDACTED]"`) {
+		t.Fatalf("expected both sides of the conflict, got:\n%s", msg)
+	}
+
+	if msg := FormatError(err); strings.Contains(msg, "secret") || strings.Contains(msg, `"a"`) {
+		t.Fatalf("expected FormatError to mask every value, got:\n%s", msg)
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// newCmdDiff builds the subcommand that prints a key-level comparison between
+// the loaded configuration and its defaults, another file, or another
+// environment variant.
+func newCmdDiff[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "diff",
+		Usage:       "Show how the configuration differs from the defaults.",
+		UsageText:   "conf diff [--file path | --env name] [--reveal] [--json] [--color auto|always|never]",
+		Description: "Compares the loaded configuration with the built-in defaults (default), another file, or another environment variant and prints the added, removed, and changed keys. Secret and sensitive values are redacted unless --reveal is given.",
+		Flags: []cli.Flag{
+			revealFlag(),
+			&cli.StringFlag{
+				Name:  "file",
+				Usage: "compare against the configuration file at `PATH`",
+			},
+			&cli.StringFlag{
+				Name:  "env",
+				Usage: "compare against the environment variant `NAME` (e.g. dev)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the changes as JSON",
+			},
+			&cli.StringFlag{
+				Name:  "color",
+				Usage: "colorize the output: auto, always, or never",
+				Value: "auto",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			file, env := cmd.String("file"), cmd.String("env")
+			if file != "" && env != "" {
+				return fmt.Errorf("--file and --env are mutually exclusive")
+			}
+
+			var (
+				changes []c.Change
+				err     error
+			)
+			switch reveal := cmd.Bool("reveal"); {
+			case file != "" && reveal:
+				changes, err = config.DiffFile(file)
+			case file != "":
+				changes, err = config.RedactedDiffFile(file)
+			case env != "" && reveal:
+				changes, err = config.DiffEnvironment(env)
+			case env != "":
+				changes, err = config.RedactedDiffEnvironment(env)
+			case reveal:
+				changes, err = config.DiffDefaults()
+			default:
+				changes, err = config.RedactedDiffDefaults()
+			}
+			if err != nil {
+				return fmt.Errorf("diff configuration: %w", err)
+			}
+
+			if cmd.Bool("json") {
+				encoder := json.NewEncoder(cmd.Writer)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(changes)
+			}
+
+			color, err := useColor(cmd.Writer, cmd.String("color"))
+			if err != nil {
+				return err
+			}
+			printChanges(cmd.Writer, changes, color)
+			return nil
+		},
+	}
+}
+
+// printChanges writes one line per change using diff-like markers.
+func printChanges(w io.Writer, changes []c.Change, color bool) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "(no differences)")
+		return
+	}
+
+	for _, change := range changes {
+		var line, tint string
+		switch change.Kind {
+		case c.ChangeAdded:
+			line, tint = fmt.Sprintf("+ %s: %s", change.Key, formatValue(change.New)), ansiGreen
+		case c.ChangeRemoved:
+			line, tint = fmt.Sprintf("- %s: %s", change.Key, formatValue(change.Old)), ansiRed
+		default:
+			line, tint = fmt.Sprintf("~ %s: %s -> %s", change.Key, formatValue(change.Old), formatValue(change.New)), ansiYellow
+		}
+		if color {
+			line = tint + line + ansiReset
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// formatValue renders a document value compactly on a single line.
+func formatValue(value any) string {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(buf)
+}
+
+// useColor resolves the --color mode. In auto mode colors are only used when
+// writing to a terminal and NO_COLOR is unset.
+func useColor(w io.Writer, mode string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+		f, ok := w.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return false, nil
+		}
+		return info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid --color value %q: expected auto, always, or never", mode)
+	}
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	c "github.com/vekio/config"
+)
+
+// FormatError renders err for the terminal. Parse errors that carry a
+// position are followed by the offending line of the file with a caret
+// under the reported column, and write conflicts by the changes made on each
+// side; other errors are returned as is. As FormatError cannot tell which
+// settings are sensitive, the values of conflicting changes are masked.
+func FormatError(err error) string {
+	return formatError(err, maskChanges)
+}
+
+// formatError is like FormatError but passes the changes of a write
+// conflict through redact before printing them.
+func formatError(err error, redact func([]c.Change) []c.Change) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+
+	var conflict *c.ConflictError
+	if errors.As(err, &conflict) {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s\nlocal changes:\n", msg)
+		printChanges(&b, redact(conflict.Local), false)
+		b.WriteString("changes on disk:\n")
+		printChanges(&b, redact(conflict.Disk), false)
+		return strings.TrimRight(b.String(), "\n")
+	}
+
+	var perr *c.ParseError
+	if !errors.As(err, &perr) || perr.Path == "" || perr.Line == 0 {
+		return msg
+	}
+	buf, readErr := os.ReadFile(perr.Path)
+	if readErr != nil {
+		return msg
+	}
+	lines := bytes.Split(buf, []byte("\n"))
+	if perr.Line > len(lines) {
+		return msg
+	}
+
+	line := strings.TrimRight(string(lines[perr.Line-1]), "\r")
+	gutter := fmt.Sprintf("%d", perr.Line)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n %s | %s", msg, gutter, line)
+	if perr.Column > 0 {
+		fmt.Fprintf(&b, "\n %s | %s^", strings.Repeat(" ", len(gutter)), caretIndent(line, perr.Column))
+	}
+	return b.String()
+}
+
+// caretIndent returns the whitespace that puts a caret under the 1-based
+// column of line, keeping tabs so it lines up with the printed line.
+func caretIndent(line string, column int) string {
+	prefix := line[:min(column-1, len(line))]
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, prefix)
+}
+
+// formattedError carries the rendered form of an error while keeping the
+// original reachable through errors.Is and errors.As.
+type formattedError struct {
+	err error
+	msg string
+}
+
+func (e *formattedError) Error() string {
+	return e.msg
+}
+
+func (e *formattedError) Unwrap() error {
+	return e.err
+}
+
+// redactedValue is the placeholder the config package shows for masked
+// values.
+var redactedValue = c.Secret("").String()
+
+// maskChanges returns a copy of changes with every value masked.
+func maskChanges(changes []c.Change) []c.Change {
+	masked := make([]c.Change, len(changes))
+	for i, change := range changes {
+		masked[i] = c.Change{Key: change.Key, Kind: change.Kind}
+		if change.Kind != c.ChangeAdded {
+			masked[i].Old = redactedValue
+		}
+		if change.Kind != c.ChangeRemoved {
+			masked[i].New = redactedValue
+		}
+	}
+	return masked
+}
+
+// withSnippet wraps err so its message includes the details rendered by
+// FormatError, with the sensitive values of write conflicts masked by
+// redact.
+func withSnippet(err error, redact func([]c.Change) []c.Change) error {
+	if err == nil {
+		return nil
+	}
+	msg := formatError(err, redact)
+	if msg == err.Error() {
+		return err
+	}
+	return &formattedError{err: err, msg: msg}
+}
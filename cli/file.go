@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// configFlagName is the global flag that points at an explicit file.
+const configFlagName = "config"
+
+// newConfigFlag builds the global --config/-c flag registered by Attach. It
+// can also be set through the <APP>_CONFIG environment variable.
+func newConfigFlag(appName string) *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:      configFlagName,
+		Aliases:   []string{"c"},
+		Usage:     "read the configuration from `FILE` (.json, .yml, or .yaml)",
+		Sources:   cli.EnvVars(fmt.Sprintf("%s_CONFIG", strings.ToUpper(appName))),
+		TakesFile: true,
+	}
+}
+
+// selectConfigFile switches the configuration to the file given with the
+// global --config flag, if any.
+func selectConfigFile[T c.Validatable](cmd *cli.Command, config *c.ConfigFile[T]) error {
+	path := cmd.String(configFlagName)
+	if path == "" {
+		return nil
+	}
+	return config.SetFile(path)
+}
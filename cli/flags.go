@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Flags generates one flag per setting of the configuration type. Flag names
+// are the dotted keys with dots and underscores replaced by dashes, usage
+// comes from the field's doc tag, and every flag can also be set through the
+// <APP>_<KEY> environment variable. Register them on the application; the
+// values that are set are applied over the loaded configuration by Before,
+// or whenever ApplyFlags is called.
+//
+// Fields of types without a matching flag (maps, nested slices, custom
+// types) are skipped, and so are fields whose flag would clash with the
+// global --config, --profile, or --help flags, such as a top-level config
+// key.
+func (c *CLIConfig[T]) Flags() []cli.Flag {
+	fields := flagFields(c.ConfigFile)
+	flags := make([]cli.Flag, 0, len(fields))
+	for _, field := range fields {
+		if flag := newFieldFlag(field, fieldEnvVar(c.ConfigFile.AppName(), field.Key)); flag != nil {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// ApplyFlags applies the generated flags that were set on the command line
+// or through their environment variables as the highest-priority layer and
+// loads the configuration again according to the init mode.
+func (c *CLIConfig[T]) ApplyFlags(cmd *cli.Command) error {
+	values := make(map[string]any)
+	for _, field := range flagFields(c.ConfigFile) {
+		name := fieldFlagName(field.Key)
+		if cmd.IsSet(name) {
+			values[field.Key] = cmd.Value(name)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := c.ConfigFile.SetOverrides(values); err != nil {
+		return err
+	}
+	return c.load()
+}
+
+// flagFields returns the fields that can get a generated flag without
+// clashing with the global flags.
+func flagFields[T c.Validatable](config *c.ConfigFile[T]) []c.Field {
+	reserved := append(newConfigFlag(config.AppName()).Names(), newProfileFlag().Names()...)
+	if cli.HelpFlag != nil {
+		reserved = append(reserved, cli.HelpFlag.Names()...)
+	}
+	return slices.DeleteFunc(config.Fields(), func(field c.Field) bool {
+		return slices.Contains(reserved, fieldFlagName(field.Key))
+	})
+}
+
+// newFieldFlag returns the flag matching the type of field, or nil when
+// there is none.
+func newFieldFlag(field c.Field, envVar string) cli.Flag {
+	name := fieldFlagName(field.Key)
+	usage := field.Usage
+	if usage == "" {
+		usage = fmt.Sprintf("override %s", field.Key)
+	}
+	sources := cli.EnvVars(envVar)
+
+	switch t := field.Type; {
+	case t == durationType:
+		return &cli.DurationFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() == reflect.String:
+		return &cli.StringFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() == reflect.Bool:
+		return &cli.BoolFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return &cli.IntFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		return &cli.UintFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &cli.FloatFlag{Name: name, Usage: usage, Sources: sources}
+	case t.Kind() == reflect.Slice && t.Elem() == reflect.TypeOf(""):
+		return &cli.StringSliceFlag{Name: name, Usage: usage, Sources: sources}
+	default:
+		return nil
+	}
+}
+
+// fieldFlagName turns a dotted key into a flag name (server.read_timeout ->
+// server-read-timeout).
+func fieldFlagName(key string) string {
+	return strings.ToLower(strings.NewReplacer(".", "-", "_", "-").Replace(key))
+}
+
+// fieldEnvVar returns the environment variable for a dotted key
+// (server.port -> MYAPP_SERVER_PORT).
+func fieldEnvVar(appName, key string) string {
+	name := appName + "_" + key
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
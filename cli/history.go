@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdHistory builds the subcommand that lists the stored snapshots of the
+// configuration file together with a short summary of how each differs from
+// the current file.
+func newCmdHistory[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "history",
+		Usage:       "List stored snapshots of the configuration file.",
+		UsageText:   "conf history",
+		Description: "Lists the snapshots kept in the .history directory, newest first, with the number of keys added (+), removed (-), and changed (~) in the configuration file since each snapshot.",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			history, err := config.History()
+			if err != nil {
+				return err
+			}
+			if len(history) == 0 {
+				fmt.Fprintln(cmd.Writer, "(no history)")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.Writer, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSAVED\tCHANGES")
+			for _, entry := range history {
+				summary := "(unreadable)"
+				if changes, err := config.DiffHistory(entry.ID); err == nil {
+					summary = summarizeChanges(changes)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", entry.ID, entry.Time.Format(time.DateTime), summary)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// newCmdRollback builds the subcommand that restores a snapshot from the
+// history after validating it.
+func newCmdRollback[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "rollback",
+		Usage:       "Restore a snapshot from the configuration history.",
+		UsageText:   "conf rollback [--yes] [id]",
+		Description: "Validates the snapshot with the given ID (the newest one by default) and restores it. The current file is saved to the history first.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "do not ask for confirmation",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			entry, err := config.LookupHistory(cmd.Args().First())
+			if err != nil {
+				return err
+			}
+
+			if !cmd.Bool("yes") {
+				question := fmt.Sprintf("Restore snapshot %s from %s?", entry.ID, entry.Time.Format(time.DateTime))
+				ok, err := confirm(cmd, question)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.Writer, "rollback aborted")
+					return nil
+				}
+			}
+
+			if err := config.Rollback(entry.ID); err != nil {
+				return fmt.Errorf("rollback configuration: %w", err)
+			}
+			fmt.Fprintf(cmd.Writer, "restored snapshot %s\n", entry.ID)
+			return nil
+		},
+	}
+}
+
+// summarizeChanges renders a compact "+added -removed ~changed" summary.
+func summarizeChanges(changes []c.Change) string {
+	if len(changes) == 0 {
+		return "identical"
+	}
+
+	var added, removed, changed int
+	for _, change := range changes {
+		switch change.Kind {
+		case c.ChangeAdded:
+			added++
+		case c.ChangeRemoved:
+			removed++
+		default:
+			changed++
+		}
+	}
+	return fmt.Sprintf("+%d -%d ~%d", added, removed, changed)
+}
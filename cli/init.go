@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdInit builds the subcommand that creates the configuration file when
+// it does not exist yet, either from the defaults or from a template file.
+func newCmdInit[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "init",
+		Usage:       "Create the configuration file if it does not exist.",
+		UsageText:   "conf init [--template path | --from-url file:///path]",
+		Description: "Writes the default configuration, or the contents of a template file, to the configuration path. Existing files are never overwritten; use reset instead.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "template",
+				Usage: "initialize from the configuration file at `PATH`",
+			},
+			&cli.StringFlag{
+				Name:  "from-url",
+				Usage: "initialize from a local `URL` (file:// or plain path)",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			exists, err := config.Exists()
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("configuration file already exists at %s", config.Path())
+			}
+
+			template := cmd.String("template")
+			if rawURL := cmd.String("from-url"); rawURL != "" {
+				if template != "" {
+					return fmt.Errorf("--template and --from-url are mutually exclusive")
+				}
+				if template, err = localPathFromURL(rawURL); err != nil {
+					return err
+				}
+			}
+
+			if template == "" {
+				err = config.Init(config.DefaultData())
+			} else {
+				err = config.InitFrom(template)
+			}
+			if err != nil {
+				return fmt.Errorf("initialize configuration: %w", err)
+			}
+
+			fmt.Fprintf(cmd.Writer, "configuration file created at %s\n", config.Path())
+			return nil
+		},
+	}
+}
+
+// localPathFromURL resolves a file:// URL or a plain path to a local path.
+// Remote schemes are rejected.
+func localPathFromURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse --from-url: %w", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		return rawURL, nil
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("--from-url: remote file host %q is not supported", u.Host)
+		}
+		if u.Path == "" {
+			return "", fmt.Errorf("--from-url: missing path in %q", rawURL)
+		}
+		return u.Path, nil
+	default:
+		return "", fmt.Errorf("--from-url: unsupported scheme %q, only local file URLs are supported", u.Scheme)
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdLint builds the subcommand that lists the deprecated and renamed keys
+// used by the configuration file.
+func newCmdLint[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "lint",
+		Usage:       "List deprecated keys used by the configuration file.",
+		UsageText:   "conf lint",
+		Description: "Prints every deprecated or renamed key found in the configuration file together with the key that replaces it. Exits with an error when any are found.",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			deprecations, err := config.Lint()
+			if err != nil {
+				return withSnippet(err, config.RedactChanges)
+			}
+			if len(deprecations) == 0 {
+				fmt.Fprintln(cmd.Writer, "no deprecated keys found")
+				return nil
+			}
+
+			for _, deprecation := range deprecations {
+				line := deprecation.Key
+				switch {
+				case deprecation.Ignored:
+					line += fmt.Sprintf(" (ignored, %s is set)", deprecation.Replacement)
+				case deprecation.Replacement != "":
+					line += " -> " + deprecation.Replacement
+				}
+				if deprecation.Message != "" {
+					line += ": " + deprecation.Message
+				}
+				fmt.Fprintln(cmd.Writer, line)
+			}
+			return fmt.Errorf("%s uses %d deprecated keys", config.Path(), len(deprecations))
+		},
+	}
+}
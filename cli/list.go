@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdList builds the subcommand that prints every configuration key with
+// its value, one dotted key per line. Keys locked by the policy file are
+// marked.
+func newCmdList[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "list",
+		Usage:       "List configuration keys and their values.",
+		UsageText:   "conf list [--reveal]",
+		Description: "Prints every configuration key as a dotted path followed by its value. Secret and sensitive values are redacted unless --reveal is given. Keys locked by the administrator's policy file are marked with (locked).",
+		Flags: []cli.Flag{
+			revealFlag(),
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			var (
+				values map[string]any
+				err    error
+			)
+			if cmd.Bool("reveal") {
+				values, err = config.Values()
+			} else {
+				values, err = config.RedactedValues()
+			}
+			if err != nil {
+				return err
+			}
+
+			locked, err := config.LockedKeys()
+			if err != nil {
+				return err
+			}
+
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				line := fmt.Sprintf("%s = %s", key, formatValue(values[key]))
+				if isLocked(locked, key) {
+					line += " (locked)"
+				}
+				fmt.Fprintln(cmd.Writer, line)
+			}
+			return nil
+		},
+	}
+}
+
+// isLocked reports whether key is one of the locked keys or lies under one.
+func isLocked(locked []string, key string) bool {
+	for _, lockedKey := range locked {
+		if key == lockedKey || strings.HasPrefix(key, lockedKey+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// profileFlagName is the global flag that selects a configuration profile.
+const profileFlagName = "profile"
+
+// newProfileFlag builds the global --profile flag registered by Attach.
+func newProfileFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:  profileFlagName,
+		Usage: "use the configuration profile `NAME`",
+	}
+}
+
+// selectProfile selects the profile given with the global --profile flag, if
+// any. Loading a profile without a file is reported as an error. The flag is
+// ignored when an explicit file is given with --config.
+func selectProfile[T c.Validatable](cmd *cli.Command, config *c.ConfigFile[T]) {
+	if name := cmd.String(profileFlagName); name != "" && cmd.String(configFlagName) == "" {
+		config.SetProfile(name)
+	}
+}
+
+// isProfileNotFound reports whether err is caused by a missing profile.
+func isProfileNotFound(err error) bool {
+	return errors.Is(err, c.ErrProfileNotFound)
+}
+
+// newCmdProfile builds the subcommand namespace that lists, switches,
+// creates, copies, and deletes configuration profiles.
+func newCmdProfile[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "profile",
+		Usage:       "Manage named configuration profiles.",
+		UsageText:   "conf profile [command]",
+		Description: "Profiles are alternative configuration files stored next to the base file as config.<profile>.<ext> and deep-merged over the base file, or over the profile named by their extends key. The selected profile is persisted and can be overridden with --profile or the <APP>_PROFILE variable.",
+		Commands: []*cli.Command{
+			newCmdProfileList(config),
+			newCmdProfileUse(config),
+			newCmdProfileCreate(config),
+			newCmdProfileCopy(config),
+			newCmdProfileDelete(config),
+		},
+	}
+}
+
+func newCmdProfileList[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List the available profiles, marking the active one.",
+		UsageText: "conf profile list",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			profiles, err := config.Profiles()
+			if err != nil {
+				return err
+			}
+
+			active := config.Profile()
+			for _, profile := range profiles {
+				marker := " "
+				if profile == active {
+					marker = "*"
+				}
+				fmt.Fprintf(cmd.Writer, "%s %s\n", marker, profile)
+			}
+			return nil
+		},
+	}
+}
+
+func newCmdProfileUse[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "use",
+		Usage:     "Make a profile the current one.",
+		UsageText: "conf profile use <name>",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			name, err := profileArg(cmd, 0)
+			if err != nil {
+				return err
+			}
+			if err := config.UseProfile(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.Writer, "using profile %s\n", config.Profile())
+			return nil
+		},
+	}
+}
+
+func newCmdProfileCreate[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "create",
+		Usage:     "Create an empty profile that inherits from the base configuration.",
+		UsageText: "conf profile create <name>",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			name, err := profileArg(cmd, 0)
+			if err != nil {
+				return err
+			}
+			if err := config.CreateProfile(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.Writer, "profile %s created at %s\n", name, config.ProfilePath(name))
+			return nil
+		},
+	}
+}
+
+func newCmdProfileCopy[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "copy",
+		Usage:     "Create a profile from the content of another one.",
+		UsageText: "conf profile copy <source> <name>",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			src, err := profileArg(cmd, 0)
+			if err != nil {
+				return err
+			}
+			dst, err := profileArg(cmd, 1)
+			if err != nil {
+				return err
+			}
+			if err := config.CopyProfile(src, dst); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.Writer, "profile %s copied to %s\n", src, dst)
+			return nil
+		},
+	}
+}
+
+func newCmdProfileDelete[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "delete",
+		Usage:     "Delete a profile.",
+		UsageText: "conf profile delete [--yes] <name>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "do not ask for confirmation",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			name, err := profileArg(cmd, 0)
+			if err != nil {
+				return err
+			}
+			if !cmd.Bool("yes") {
+				ok, err := confirm(cmd, fmt.Sprintf("Delete profile %s?", name))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.Writer, "delete aborted")
+					return nil
+				}
+			}
+			if err := config.DeleteProfile(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.Writer, "profile %s deleted\n", name)
+			return nil
+		},
+	}
+}
+
+// profileArg returns the positional argument at index, failing with the
+// command's usage when it is missing.
+func profileArg(cmd *cli.Command, index int) (string, error) {
+	name := cmd.Args().Get(index)
+	if name == "" {
+		return "", fmt.Errorf("missing profile name; usage: %s", cmd.UsageText)
+	}
+	return name, nil
+}
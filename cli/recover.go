@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdRecover builds the subcommand namespace that lists, inspects, and
+// restores configuration files quarantined because they were corrupt.
+func newCmdRecover[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "recover",
+		Usage:       "Inspect and restore quarantined configuration files.",
+		UsageText:   "conf recover [command]",
+		Description: "When recovery is enabled, a configuration file that cannot be parsed is moved aside to <file>.corrupt-<timestamp> and replaced with the newest readable snapshot or the defaults. These commands work without loading the configuration, so they remain usable while the file is corrupt.",
+		Commands: []*cli.Command{
+			newCmdRecoverList(config),
+			newCmdRecoverShow(config),
+			newCmdRecoverRestore(config),
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			return listQuarantined(cmd, config)
+		},
+	}
+}
+
+func newCmdRecoverList[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List the quarantined files, newest first.",
+		UsageText: "conf recover list",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			return listQuarantined(cmd, config)
+		},
+	}
+}
+
+func newCmdRecoverShow[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "show",
+		Usage:       "Print the content of a quarantined file.",
+		UsageText:   "conf recover show [--reveal] [id]",
+		Description: "Prints the quarantined file with the given ID (the newest one by default). Secret and sensitive values are redacted unless --reveal is given.",
+		Flags: []cli.Flag{
+			revealFlag(),
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			id := cmd.Args().First()
+			if !cmd.Bool("reveal") {
+				buf, err := config.RedactedQuarantined(id)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.Writer.Write(buf)
+				return err
+			}
+
+			entry, err := config.LookupQuarantined(id)
+			if err != nil {
+				return err
+			}
+			buf, err := os.ReadFile(entry.Path)
+			if err != nil {
+				return fmt.Errorf("read quarantined file: %w", err)
+			}
+			_, err = cmd.Writer.Write(buf)
+			return err
+		},
+	}
+}
+
+func newCmdRecoverRestore[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "restore",
+		Usage:       "Put a quarantined file back in place.",
+		UsageText:   "conf recover restore [--yes] [id]",
+		Description: "Moves the quarantined file with the given ID (the newest one by default) back to the configuration path. The current file is saved to the history first.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "do not ask for confirmation",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			entry, err := config.LookupQuarantined(cmd.Args().First())
+			if err != nil {
+				return err
+			}
+			if !cmd.Bool("yes") {
+				ok, err := confirm(cmd, fmt.Sprintf("Replace %s with %s?", config.Path(), entry.Path))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.Writer, "restore aborted")
+					return nil
+				}
+			}
+			if err := config.RestoreQuarantined(entry.ID); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.Writer, "restored %s to %s\n", entry.ID, config.Path())
+			return nil
+		},
+	}
+}
+
+// listQuarantined prints the quarantined files of config as a table.
+func listQuarantined[T c.Validatable](cmd *cli.Command, config *c.ConfigFile[T]) error {
+	entries, err := config.Quarantined()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(cmd.Writer, "(no quarantined files)")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tQUARANTINED\tPATH")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.ID, entry.Time.Format(time.DateTime), entry.Path)
+	}
+	return w.Flush()
+}
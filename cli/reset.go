@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdReset builds the subcommand that restores the whole configuration
+// file, or only the given keys, to the default values after backing up the
+// current file.
+func newCmdReset[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "reset",
+		Usage:       "Restore the configuration, or specific keys, to the defaults.",
+		UsageText:   "conf reset [--yes] [key...]",
+		Description: "Backs up the current configuration file and then restores either the whole file or the listed dotted keys to their default values. Asks for confirmation unless --yes is given.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "do not ask for confirmation",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			keys := cmd.Args().Slice()
+
+			target := "the whole configuration"
+			if len(keys) > 0 {
+				target = strings.Join(keys, ", ")
+			}
+			if !cmd.Bool("yes") {
+				ok, err := confirm(cmd, fmt.Sprintf("Reset %s to the defaults?", target))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.Writer, "reset aborted")
+					return nil
+				}
+			}
+
+			backup, err := config.Backup()
+			if err != nil {
+				return fmt.Errorf("back up configuration: %w", err)
+			}
+			if err := config.Reset(keys...); err != nil {
+				return withSnippet(err, config.RedactChanges)
+			}
+
+			if backup != "" {
+				fmt.Fprintf(cmd.Writer, "previous configuration saved to %s\n", backup)
+			}
+			fmt.Fprintf(cmd.Writer, "reset %s\n", target)
+			return nil
+		},
+	}
+}
+
+// confirm asks a yes/no question on the command's writer and reads the
+// answer from its reader. Anything other than y/yes is treated as no.
+func confirm(cmd *cli.Command, question string) (bool, error) {
+	var reader io.Reader = os.Stdin
+	if cmd.Root().Reader != nil {
+		reader = cmd.Root().Reader
+	}
+
+	fmt.Fprintf(cmd.Writer, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(reader).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("read confirmation: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
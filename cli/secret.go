@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+	"github.com/vekio/x/fs"
+)
+
+// newCmdEncrypt builds the subcommand that rewrites the configuration file
+// with every secret field encrypted.
+func newCmdEncrypt[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "encrypt",
+		Usage:       "Encrypt the secret fields stored in the configuration file.",
+		UsageText:   "conf encrypt",
+		Description: "Reloads the configuration and writes it back with every Secret field (or field tagged secret:\"true\") encrypted with the configured key.",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := config.Reload(); err != nil {
+				return fmt.Errorf("reload configuration: %w", err)
+			}
+			if err := config.EncryptSecrets(); err != nil {
+				return fmt.Errorf("encrypt secrets: %w", err)
+			}
+			fmt.Fprintln(cmd.Writer, "secret fields encrypted")
+			return nil
+		},
+	}
+}
+
+// newCmdDecrypt builds the subcommand that rewrites the configuration file
+// with every secret field in plain text.
+func newCmdDecrypt[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "decrypt",
+		Usage:       "Store the secret fields of the configuration file in plain text.",
+		UsageText:   "conf decrypt",
+		Description: "Reloads the configuration and writes it back with every secret field decrypted. The next write encrypts them again.",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := config.Reload(); err != nil {
+				return fmt.Errorf("reload configuration: %w", err)
+			}
+			if err := config.DecryptSecrets(); err != nil {
+				return fmt.Errorf("decrypt secrets: %w", err)
+			}
+			fmt.Fprintln(cmd.Writer, "secret fields decrypted")
+			return nil
+		},
+	}
+}
+
+// newCmdRotateKey builds the subcommand that re-encrypts the secret fields
+// with a new key read from, or generated into, a key file.
+func newCmdRotateKey[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "rotate-key",
+		Usage:       "Re-encrypt the secret fields with a new key.",
+		UsageText:   "conf rotate-key --new-key-file path",
+		Description: "Decrypts the secret fields with the current key and encrypts them with the key stored in --new-key-file. A new random key is generated into that file when it does not exist yet.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "new-key-file",
+				Usage:    "read the new key from (or generate it into) `PATH`",
+				Required: true,
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			path := cmd.String("new-key-file")
+
+			key, generated, err := loadOrGenerateKey(path)
+			if err != nil {
+				return err
+			}
+			if err := config.RotateSecretKey(key); err != nil {
+				return fmt.Errorf("rotate secret key: %w", err)
+			}
+
+			if generated {
+				fmt.Fprintf(cmd.Writer, "generated new key in %s\n", path)
+			}
+			fmt.Fprintln(cmd.Writer, "secret fields re-encrypted; update the configured key file or environment variable to use the new key")
+			return nil
+		},
+	}
+}
+
+// loadOrGenerateKey reads the key stored at path, generating and saving a
+// new one when the file does not exist.
+func loadOrGenerateKey(path string) ([]byte, bool, error) {
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		key, err := c.ParseSecretKey(raw)
+		return key, false, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("read key file: %w", err)
+	}
+
+	key, err := c.GenerateSecretKey()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := fs.WriteFileWithDirs(path, []byte(c.EncodeSecretKey(key)+"\n"), fs.RestrictedFileMode); err != nil {
+		return nil, false, fmt.Errorf("write key file: %w", err)
+	}
+	return key, true, nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+)
+
+// newCmdSet builds the subcommand that changes a single setting and writes
+// the configuration file.
+func newCmdSet[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "set",
+		Usage:       "Change a configuration key.",
+		UsageText:   "conf set <key> <value...>",
+		Description: "Parses the value according to the type of the dotted key and writes it to the configuration file. List settings take one value per argument. Keys locked by the administrator's policy file cannot be changed.",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			args := cmd.Args().Slice()
+			if len(args) < 2 {
+				return errors.New("a key and a value are required")
+			}
+			key := args[0]
+
+			var field *c.Field
+			for _, candidate := range config.Fields() {
+				if candidate.Key == key {
+					field = &candidate
+					break
+				}
+			}
+			if field == nil {
+				return fmt.Errorf("unknown key %q", key)
+			}
+			value, err := parseFieldValue(field.Type, args[1:])
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if err := config.Set(key, value); err != nil {
+				if errors.Is(err, c.ErrLocked) {
+					return fmt.Errorf("%s is locked by %s", key, config.PolicyPath())
+				}
+				return withSnippet(err, config.RedactChanges)
+			}
+
+			shown := formatValue(value)
+			if field.Sensitive {
+				shown = formatValue(redactedValue)
+			}
+			fmt.Fprintf(cmd.Writer, "%s = %s\n", key, shown)
+			return nil
+		},
+	}
+}
+
+// parseFieldValue converts command-line arguments into a value of type t.
+// Only slices of strings accept more than one argument. Numbers that do not
+// fit in t are rejected.
+func parseFieldValue(t reflect.Type, args []string) (any, error) {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String {
+		return args, nil
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected a single value, got %d", len(args))
+	}
+
+	raw := strings.TrimSpace(args[0])
+	switch kind := t.Kind(); {
+	case t == durationType:
+		return time.ParseDuration(raw)
+	case kind == reflect.String:
+		return args[0], nil
+	case kind == reflect.Bool:
+		return strconv.ParseBool(raw)
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		return strconv.ParseInt(raw, 10, t.Bits())
+	case kind >= reflect.Uint && kind <= reflect.Uint64:
+		return strconv.ParseUint(raw, 10, t.Bits())
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		return strconv.ParseFloat(raw, t.Bits())
+	default:
+		return nil, fmt.Errorf("cannot set values of type %s", t)
+	}
+}
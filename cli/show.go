@@ -8,16 +8,38 @@ import (
 	c "github.com/vekio/config"
 )
 
-// newCmdShow builds the subcommand that prints the raw configuration
-// file to stdout so users can quickly inspect the stored values.
+// newCmdShow builds the subcommand that prints the configuration file to
+// stdout so users can quickly inspect the stored values. --effective shows
+// the values resolved from every layer instead, and sensitive values are
+// redacted in both unless --reveal is given.
 func newCmdShow[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
 	cmd := &cli.Command{
 		Name:        "show",
 		Usage:       "Display the current configuration file contents.",
-		UsageText:   "conf show",
-		Description: "Reads the configuration file from disk and writes its contents to standard output.",
+		UsageText:   "conf show [--reveal] [--effective]",
+		Description: "Prints the contents of the configuration file. With --effective the configuration resolved from every layer, including the conditional sections that match this machine, is printed instead. Secret and sensitive values are redacted unless --reveal is given.",
+		Flags: []cli.Flag{
+			revealFlag(),
+			&cli.BoolFlag{
+				Name:  "effective",
+				Usage: "print the configuration resolved from every layer instead of the file",
+			},
+		},
 		Action: func(_ context.Context, cmd *cli.Command) error {
-			buf, err := config.Content()
+			var (
+				buf []byte
+				err error
+			)
+			switch reveal, effective := cmd.Bool("reveal"), cmd.Bool("effective"); {
+			case effective && reveal:
+				buf, err = config.EffectiveContent()
+			case effective:
+				buf, err = config.RedactedEffectiveContent()
+			case reveal:
+				buf, err = config.Content()
+			default:
+				buf, err = config.RedactedContent()
+			}
 			if err != nil {
 				return fmt.Errorf("read configuration: %w", err)
 			}
@@ -31,3 +53,11 @@ func newCmdShow[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
 	}
 	return cmd
 }
+
+// revealFlag opts out of redaction explicitly.
+func revealFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "reveal",
+		Usage: "print secret and sensitive values in plain text",
+	}
+}
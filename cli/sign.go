@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v3"
+	c "github.com/vekio/config"
+	"github.com/vekio/x/fs"
+)
+
+// newCmdSign builds the subcommand that signs the configuration file with a
+// private key read from, or generated into, a key file.
+func newCmdSign[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "sign",
+		Usage:       "Sign the configuration file.",
+		UsageText:   "conf sign --key-file path [file...]",
+		Description: "Signs the configuration file, and any other files given as arguments such as drop-in or included files, with the Ed25519 private key stored in --key-file and writes each signature next to its file with a .sig suffix. A new key pair is generated when the file does not exist yet; the public key is saved to the same path with a .pub suffix so it can be distributed as a trusted key.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "key-file",
+				Usage:    "read the private key from (or generate it into) `PATH`",
+				Required: true,
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			path := cmd.String("key-file")
+
+			key, generated, err := loadOrGenerateSigningKey(path)
+			if err != nil {
+				return err
+			}
+			if err := config.Sign(key); err != nil {
+				return fmt.Errorf("sign configuration file: %w", err)
+			}
+			for _, file := range cmd.Args().Slice() {
+				if err := c.SignFile(file, key); err != nil {
+					return fmt.Errorf("sign %s: %w", file, err)
+				}
+			}
+
+			if generated {
+				fmt.Fprintf(cmd.Writer, "generated new key pair in %s and %s.pub\n", path, path)
+			}
+			fmt.Fprintf(cmd.Writer, "signature written to %s\n", config.SignaturePath())
+			for _, file := range cmd.Args().Slice() {
+				fmt.Fprintf(cmd.Writer, "signature written to %s.sig\n", file)
+			}
+			return nil
+		},
+	}
+}
+
+// newCmdVerify builds the subcommand that checks the signature of the
+// configuration file.
+func newCmdVerify[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
+	return &cli.Command{
+		Name:        "verify",
+		Usage:       "Verify the signature of the configuration file.",
+		UsageText:   "conf verify [--public-key-file path...]",
+		Description: "Checks the .sig file next to the configuration file against the public keys given with --public-key-file, or against the keys the application trusts when none are given. Exits with an error when the file is unsigned or was modified after signing.",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "public-key-file",
+				Usage: "trust the public key stored in `PATH` (repeatable)",
+			},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			paths := cmd.StringSlice("public-key-file")
+
+			var err error
+			if len(paths) == 0 {
+				err = config.Verify()
+			} else {
+				keys := make([]ed25519.PublicKey, 0, len(paths))
+				for _, path := range paths {
+					raw, readErr := os.ReadFile(path)
+					if readErr != nil {
+						return fmt.Errorf("read public key file: %w", readErr)
+					}
+					key, parseErr := c.ParsePublicKey(raw)
+					if parseErr != nil {
+						return fmt.Errorf("%s: %w", path, parseErr)
+					}
+					keys = append(keys, key)
+				}
+				err = config.VerifyWith(keys...)
+			}
+			if errors.Is(err, c.ErrNoTrustedKeys) {
+				return fmt.Errorf("%w; pass --public-key-file", err)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.Writer, "%s: signature ok\n", config.Path())
+			return nil
+		},
+	}
+}
+
+// loadOrGenerateSigningKey reads the private key stored at path, generating
+// a new key pair when the file does not exist. The public key is saved to
+// path.pub.
+func loadOrGenerateSigningKey(path string) (ed25519.PrivateKey, bool, error) {
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		key, err := c.ParsePrivateKey(raw)
+		return key, false, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("read key file: %w", err)
+	}
+
+	public, private, err := c.GenerateSigningKey()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := fs.WriteFileWithDirs(path, []byte(c.EncodeSigningKey(private)+"\n"), fs.RestrictedFileMode); err != nil {
+		return nil, false, fmt.Errorf("write key file: %w", err)
+	}
+	if err := fs.WriteFileWithDirs(path+".pub", []byte(c.EncodeSigningKey(public)+"\n"), fs.DefaultFileMode); err != nil {
+		return nil, false, fmt.Errorf("write public key file: %w", err)
+	}
+	return private, true, nil
+}
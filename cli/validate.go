@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/urfave/cli/v3"
@@ -17,12 +18,17 @@ func newCmdValidate[T c.Validatable](config *c.ConfigFile[T]) *cli.Command {
 		UsageText:   "conf validate",
 		Description: "Reloads the configuration file from disk and runs the Validate method implemented by the consumer-provided config struct.",
 		Action: func(_ context.Context, cmd *cli.Command) error {
-			if err := config.Reload(); err != nil {
-				return fmt.Errorf("reload configuration: %w", err)
+			var invalid *c.ValidationError
+			err := config.Reload()
+			if errors.As(err, &invalid) {
+				return fmt.Errorf("validation failed: %w", invalid.Err)
+			}
+			if err != nil {
+				return fmt.Errorf("reload configuration: %w", withSnippet(err, config.RedactChanges))
 			}
 			data := config.Data()
 			if err := data.Validate(); err != nil {
-				return fmt.Errorf("validation failed: %w", err)
+				return fmt.Errorf("validation failed: %w", config.RedactError(err))
 			}
 			fmt.Fprintln(cmd.Writer, "configuration is valid")
 			return nil
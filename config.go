@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 )
 
@@ -20,7 +21,7 @@ func NewJSONConfigFile[T Validatable](options ...ConfigFileOption[T]) (*ConfigFi
 }
 
 // NewDefaultConfigFile builds a YAML configuration backed by the user's
-// configuration directory (falling back to the OS temp dir when unavailable).
+// configuration directory ($XDG_CONFIG_HOME or the platform default).
 func NewDefaultConfigFile[T Validatable](options ...ConfigFileOption[T]) (*ConfigFile[T], error) {
 	return NewYAMLConfigFile(options...)
 }
@@ -29,6 +30,10 @@ func newConfigFile[T Validatable](manager FileManager[T], options ...ConfigFileO
 	if manager == nil {
 		return nil, fmt.Errorf("config: file manager must not be nil")
 	}
+	documents, ok := manager.(documentManager[T])
+	if !ok {
+		return nil, fmt.Errorf("config: file manager %T does not support documents", manager)
+	}
 
 	extension := strings.TrimPrefix(manager.Extension(), ".")
 
@@ -38,11 +43,13 @@ func newConfigFile[T Validatable](manager FileManager[T], options ...ConfigFileO
 	}
 
 	c := &ConfigFile[T]{
-		fileManager: manager,
+		fileManager: documents,
 		appName:     defaultAppName(),
-		path:        defaultConfigPath(),
 		fileName:    fileName,
 		defaultData: *new(T),
+
+		historyRetention: defaultHistoryRetention,
+		secretProviders:  defaultSecretProviders(),
 	}
 
 	for _, option := range options {
@@ -51,5 +58,32 @@ func newConfigFile[T Validatable](manager FileManager[T], options ...ConfigFileO
 		}
 	}
 
+	if c.file != "" {
+		if err := c.SetFile(c.file); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.path == "" && c.file == "" {
+		path, err := defaultConfigPath()
+		if err != nil {
+			return nil, fmt.Errorf("%w; set $XDG_CONFIG_HOME or $HOME, or use WithPath", err)
+		}
+		c.path = path
+	}
+
 	return c, nil
 }
+
+// fileManagerFor returns the file manager for the format implied by the
+// extension of path.
+func fileManagerFor[T Validatable](path string) (documentManager[T], error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return NewJSONFileManager[T](), nil
+	case ".yml", ".yaml":
+		return NewYAMLFileManager[T](), nil
+	default:
+		return nil, fmt.Errorf("config: cannot detect the format of %s: unsupported extension %q", path, ext)
+	}
+}
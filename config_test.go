@@ -2,11 +2,16 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 type testSettings struct {
@@ -16,6 +21,41 @@ type testSettings struct {
 
 func (t testSettings) Validate() error { return nil }
 
+type secretSettings struct {
+	Name   string            `json:"name" yaml:"name"`
+	Token  Secret            `json:"token" yaml:"token"`
+	APIKey string            `json:"api_key" yaml:"api_key" secret:"true"`
+	Extra  map[string]Secret `json:"extra" yaml:"extra"`
+}
+
+func (s secretSettings) Validate() error { return nil }
+
+type sensitiveSettings struct {
+	User     string   `json:"user" yaml:"user"`
+	Password string   `json:"password" yaml:"password" sensitive:"true"`
+	Token    Secret   `json:"token" yaml:"token"`
+	Hosts    []string `json:"hosts" yaml:"hosts" sensitive:"true"`
+}
+
+func (s sensitiveSettings) Validate() error {
+	if s.User == "" {
+		return fmt.Errorf("user is required (password %q)", s.Password)
+	}
+	return nil
+}
+
+type strictSettings struct {
+	Name string `json:"name" yaml:"name"`
+	Port int    `json:"port" yaml:"port"`
+}
+
+func (s strictSettings) Validate() error {
+	if s.Port < 0 {
+		return fmt.Errorf("port must not be negative, got %d", s.Port)
+	}
+	return nil
+}
+
 func TestNewYAMLConfigFile(t *testing.T) {
 	cfg, err := NewYAMLConfigFile[testSettings]()
 	if err != nil {
@@ -56,6 +96,18 @@ func TestNewConfigFileNilManager(t *testing.T) {
 	}
 }
 
+type plainFileManager struct{}
+
+func (plainFileManager) LoadDataFromFile(string, *testSettings) error { return nil }
+func (plainFileManager) WriteDataToFile(string, testSettings) error   { return nil }
+func (plainFileManager) Extension() string                            { return ".txt" }
+
+func TestNewConfigFileWithoutDocuments(t *testing.T) {
+	if _, err := newConfigFile[testSettings](plainFileManager{}); err == nil {
+		t.Fatalf("expected error when the manager does not support documents")
+	}
+}
+
 func TestWithDefault(t *testing.T) {
 	cfg := newTestConfigFile(t)
 	defaults := testSettings{Name: "default", Port: 1}
@@ -351,28 +403,2113 @@ func TestYAMLFileManagerRoundTrip(t *testing.T) {
 	}
 }
 
-func newTestConfigFile(t *testing.T) *ConfigFile[testSettings] {
-	t.Helper()
-	return &ConfigFile[testSettings]{
-		fileManager: NewJSONFileManager[testSettings](),
-		fileName:    "config.json",
-		appName:     "app",
-		path:        t.TempDir(),
+func TestDiff(t *testing.T) {
+	from := map[string]any{
+		"name":   "a",
+		"port":   1,
+		"nested": map[string]any{"keep": true, "drop": "x"},
+	}
+	to := map[string]any{
+		"name":   "b",
+		"port":   1,
+		"nested": map[string]any{"keep": true},
+		"extra":  []any{"y"},
+	}
+
+	changes := Diff(from, to)
+	want := []Change{
+		{Key: "extra", Kind: ChangeAdded, New: []any{"y"}},
+		{Key: "name", Kind: ChangeModified, Old: "a", New: "b"},
+		{Key: "nested.drop", Kind: ChangeRemoved, Old: "x"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %d: %+v", len(want), len(changes), changes)
+	}
+	for i := range want {
+		if changes[i].Key != want[i].Key || changes[i].Kind != want[i].Kind {
+			t.Fatalf("change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
 	}
 }
 
-func mustNewTestConfigFile(t *testing.T, opts ...ConfigFileOption[testSettings]) *ConfigFile[testSettings] {
-	t.Helper()
-	tempPath := t.TempDir()
-	options := []ConfigFileOption[testSettings]{
-		WithPath[testSettings](tempPath),
-		WithAppName[testSettings]("testapp"),
+func TestDiffDefaults(t *testing.T) {
+	defaults := testSettings{Name: "default", Port: 1}
+	cfg := mustNewTestConfigFile(t, WithDefault(defaults))
+	if err := cfg.Init(testSettings{Name: "custom", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
 	}
-	options = append(options, opts...)
 
-	cfg, err := NewJSONConfigFile(options...)
+	changes, err := cfg.DiffDefaults()
+	if err != nil {
+		t.Fatalf("DiffDefaults failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "name" || changes[0].Kind != ChangeModified {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if changes[0].Old != "default" || changes[0].New != "custom" {
+		t.Fatalf("unexpected values: %+v", changes[0])
+	}
+}
+
+func TestDiffEnvironment(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	if err := cfg.Init(testSettings{Name: "base", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	envPath := filepath.Join(cfg.DirPath(), "config.dev.json")
+	if err := os.WriteFile(envPath, []byte(`{"name":"base","port":2}`), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	changes, err := cfg.DiffEnvironment("DEV")
+	if err != nil {
+		t.Fatalf("DiffEnvironment failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "port" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	if _, err := cfg.DiffEnvironment("missing"); err == nil {
+		t.Fatalf("expected error for missing environment file")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+
+	backup, err := cfg.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if backup != "" {
+		t.Fatalf("expected no backup for missing file, got %q", backup)
+	}
+
+	if err := cfg.Init(testSettings{Name: "saved", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	backup, err = cfg.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	got, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	want, _ := cfg.Content()
+	if string(got) != string(want) {
+		t.Fatalf("backup mismatch: got %q want %q", got, want)
+	}
+}
+
+func TestResetKeys(t *testing.T) {
+	defaults := testSettings{Name: "default", Port: 1}
+	cfg := mustNewTestConfigFile(t, WithDefault(defaults))
+	if err := cfg.Init(testSettings{Name: "custom", Port: 2}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if err := cfg.Reset("port"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "custom", Port: 1}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Reset("missing"); err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+
+	if err := cfg.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if got := cfg.Data(); got != defaults {
+		t.Fatalf("expected data %+v, got %+v", defaults, got)
+	}
+}
+
+func TestInitFrom(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	template := filepath.Join(t.TempDir(), "template.json")
+	if err := os.WriteFile(template, []byte(`{"name":"template","port":9}`), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	if err := cfg.InitFrom(template); err != nil {
+		t.Fatalf("InitFrom failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "template", Port: 9}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+	if exists, err := cfg.Exists(); err != nil || !exists {
+		t.Fatalf("expected file to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithHistory[testSettings](2))
+
+	for port := 1; port <= 4; port++ {
+		if err := cfg.Init(testSettings{Name: "history", Port: port}); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+	}
+
+	history, err := cfg.History()
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(history))
+	}
+
+	var newest testSettings
+	buf, err := os.ReadFile(history[0].Path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	if err := json.Unmarshal(buf, &newest); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if newest.Port != 3 {
+		t.Fatalf("expected newest snapshot to hold port 3, got %d", newest.Port)
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithHistory[testSettings](0))
+	for port := 1; port <= 2; port++ {
+		if err := cfg.Init(testSettings{Name: "history", Port: port}); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+	}
+
+	history, err := cfg.History()
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("expected no snapshots, got %d", len(history))
+	}
+
+	if _, err := cfg.Backup(); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := cfg.Init(testSettings{Name: "history", Port: 3}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Rollback(""); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if history, _ = cfg.History(); len(history) != 1 {
+		t.Fatalf("expected Rollback not to add snapshots, got %d", len(history))
+	}
+}
+
+func TestRollback(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	if err := cfg.Init(testSettings{Name: "first", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Init(testSettings{Name: "second", Port: 2}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if err := cfg.Rollback(""); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "first", Port: 1}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "first", Port: 1}); got != want {
+		t.Fatalf("expected reloaded data %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Rollback("19990101T000000.000000000"); !errors.Is(err, ErrHistoryNotFound) {
+		t.Fatalf("expected ErrHistoryNotFound, got %v", err)
+	}
+}
+
+func TestDiffHistory(t *testing.T) {
+	t.Setenv("CFG_NAME", "svc")
+	cfg := mustNewTestConfigFile(t, WithInterpolation[testSettings]())
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "10-port.json"), `{"port":10}`)
+	writeTestFile(t, cfg.Path(), `{"name":"${CFG_NAME}"}`)
+	if _, err := cfg.Backup(); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	writeTestFile(t, cfg.Path(), `{"name":"svc","port":10}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	changes, err := cfg.DiffHistory("")
+	if err != nil {
+		t.Fatalf("DiffHistory failed: %v", err)
+	}
+	want := []Change{
+		{Key: "name", Kind: ChangeModified, Old: "${CFG_NAME}", New: "svc"},
+		{Key: "port", Kind: ChangeAdded, New: json.Number("10")},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected changes %+v, got %+v", want, changes)
+	}
+}
+
+func TestRollbackWithDropIns(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithHistory[testSettings](5))
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "10-port.json"), `{"port":10}`)
+	writeTestFile(t, cfg.Path(), `{"name":"first"}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	data := cfg.Data()
+	data.Name = "second"
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	entry, err := cfg.LookupHistory("")
+	if err != nil {
+		t.Fatalf("LookupHistory failed: %v", err)
+	}
+	changes, err := cfg.DiffFile(entry.Path)
+	if err != nil {
+		t.Fatalf("DiffFile failed: %v", err)
+	}
+	want := []Change{{Key: "name", Kind: ChangeModified, Old: "first", New: "second"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected changes %+v, got %+v", want, changes)
+	}
+
+	if err := cfg.Rollback(entry.ID); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "first", Port: 10}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+	data = cfg.Data()
+	data.Name = "third"
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init after Rollback failed: %v", err)
+	}
+	if doc := readJSONFile(t, cfg.Path()); doc["port"] != nil {
+		t.Fatalf("expected drop-in value to stay out of the main file, got %v", doc)
+	}
+}
+
+func TestRollbackRejectsInvalidSnapshot(t *testing.T) {
+	cfg, err := NewJSONConfigFile(
+		WithPath[strictSettings](t.TempDir()),
+		WithAppName[strictSettings]("testapp"),
+	)
 	if err != nil {
 		t.Fatalf("NewJSONConfigFile failed: %v", err)
 	}
-	return cfg
+	if err := cfg.Init(strictSettings{Name: "valid", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	snapshot := filepath.Join(cfg.HistoryDir(), "config.json.20000101T000000.000000000")
+	if err := os.MkdirAll(cfg.HistoryDir(), 0o755); err != nil {
+		t.Fatalf("create history dir: %v", err)
+	}
+	if err := os.WriteFile(snapshot, []byte(`{"name":"bad","port":-1}`), 0o600); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+
+	if err := cfg.Rollback("20000101T000000.000000000"); err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if got := cfg.Data(); got.Name != "valid" {
+		t.Fatalf("expected data to be unchanged, got %+v", got)
+	}
+}
+
+func TestSecretsEncryptedAtRest(t *testing.T) {
+	key, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+	cfg := mustNewSecretConfigFile(t, WithSecretKey[secretSettings](key))
+
+	data := secretSettings{
+		Name:   "visible",
+		Token:  "token-value",
+		APIKey: "api-key-value",
+		Extra:  map[string]Secret{"db": "db-value"},
+	}
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	content, err := cfg.Content()
+	if err != nil {
+		t.Fatalf("Content failed: %v", err)
+	}
+	for _, plain := range []string{"token-value", "api-key-value", "db-value"} {
+		if strings.Contains(string(content), plain) {
+			t.Fatalf("expected %q to be encrypted, got %s", plain, content)
+		}
+	}
+	if !strings.Contains(string(content), "visible") {
+		t.Fatalf("expected non-secret fields in plain text, got %s", content)
+	}
+	if cfg.Data().Token != "token-value" {
+		t.Fatalf("expected in-memory data to stay decrypted, got %+v", cfg.Data())
+	}
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	got := cfg.Data()
+	if got.Token != data.Token || got.APIKey != data.APIKey || got.Extra["db"] != "db-value" {
+		t.Fatalf("expected decrypted data %+v, got %+v", data, got)
+	}
+}
+
+func TestSecretsKeyFromEnv(t *testing.T) {
+	key, _ := GenerateSecretKey()
+	t.Setenv("TESTAPP_SECRET_KEY", EncodeSecretKey(key))
+	cfg := mustNewSecretConfigFile(t)
+
+	if err := cfg.Init(secretSettings{Token: "from-env"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if cfg.Data().Token != "from-env" {
+		t.Fatalf("expected token from-env, got %q", cfg.Data().Token)
+	}
+}
+
+func TestSecretsWithoutKey(t *testing.T) {
+	cfg := mustNewSecretConfigFile(t)
+	if err := cfg.Init(secretSettings{Name: "no secrets"}); err != nil {
+		t.Fatalf("Init without secret values failed: %v", err)
+	}
+	if err := cfg.Init(secretSettings{Token: "value"}); !errors.Is(err, ErrNoSecretKey) {
+		t.Fatalf("expected ErrNoSecretKey, got %v", err)
+	}
+}
+
+func TestRotateSecretKey(t *testing.T) {
+	oldKey, _ := GenerateSecretKey()
+	newKey, _ := GenerateSecretKey()
+	cfg := mustNewSecretConfigFile(t, WithSecretKey[secretSettings](oldKey))
+	if err := cfg.Init(secretSettings{Token: "rotated"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if err := cfg.RotateSecretKey(newKey); err != nil {
+		t.Fatalf("RotateSecretKey failed: %v", err)
+	}
+
+	stale := mustNewSecretConfigFile(t, WithPath[secretSettings](cfg.path), WithSecretKey[secretSettings](oldKey))
+	if err := stale.Reload(); err == nil {
+		t.Fatalf("expected old key to fail after rotation")
+	}
+	fresh := mustNewSecretConfigFile(t, WithPath[secretSettings](cfg.path), WithSecretKey[secretSettings](newKey))
+	if err := fresh.Reload(); err != nil {
+		t.Fatalf("Reload with new key failed: %v", err)
+	}
+	if fresh.Data().Token != "rotated" {
+		t.Fatalf("expected token rotated, got %q", fresh.Data().Token)
+	}
+}
+
+func TestEncryptSecretsScrubsHistory(t *testing.T) {
+	key, _ := GenerateSecretKey()
+	cfg := mustNewSecretConfigFile(t, WithSecretKey[secretSettings](key))
+	writeTestFile(t, cfg.Path(), `{"name":"first","token":"hunter2","extra":{"db":"db-value"}}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := cfg.Backup(); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	if err := cfg.EncryptSecrets(); err != nil {
+		t.Fatalf("EncryptSecrets failed: %v", err)
+	}
+	history, err := cfg.History()
+	if err != nil || len(history) != 1 {
+		t.Fatalf("expected one snapshot, got %v (%v)", history, err)
+	}
+	for _, entry := range history {
+		buf, err := os.ReadFile(entry.Path)
+		if err != nil {
+			t.Fatalf("read snapshot: %v", err)
+		}
+		for _, plain := range []string{"hunter2", "db-value"} {
+			if strings.Contains(string(buf), plain) {
+				t.Fatalf("expected %q to be encrypted in %s, got %s", plain, entry.ID, buf)
+			}
+		}
+	}
+
+	if err := cfg.Rollback(""); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if got := cfg.Data(); got.Token != "hunter2" || got.Extra["db"] != "db-value" {
+		t.Fatalf("expected the snapshot to decrypt, got %+v", got)
+	}
+}
+
+func TestRotateSecretKeyKeepsHistory(t *testing.T) {
+	oldKey, _ := GenerateSecretKey()
+	newKey, _ := GenerateSecretKey()
+	cfg := mustNewSecretConfigFile(t, WithSecretKey[secretSettings](oldKey))
+	if err := cfg.Init(secretSettings{Name: "first", Token: "first-token"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Init(secretSettings{Name: "second", Token: "second-token"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if err := cfg.RotateSecretKey(newKey); err != nil {
+		t.Fatalf("RotateSecretKey failed: %v", err)
+	}
+	if err := cfg.Rollback(""); err != nil {
+		t.Fatalf("Rollback after rotation failed: %v", err)
+	}
+	if got := cfg.Data(); got.Name != "first" || got.Token != "first-token" {
+		t.Fatalf("expected the first snapshot, got %+v", got)
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	key, _ := GenerateSecretKey()
+	for _, raw := range [][]byte{key, []byte(EncodeSecretKey(key) + "\n"), []byte(fmt.Sprintf("%x", key))} {
+		parsed, err := ParseSecretKey(raw)
+		if err != nil {
+			t.Fatalf("ParseSecretKey failed: %v", err)
+		}
+		if string(parsed) != string(key) {
+			t.Fatalf("parsed key mismatch")
+		}
+	}
+	if _, err := ParseSecretKey([]byte("short")); err == nil {
+		t.Fatalf("expected error for short key")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t)
+	data := sensitiveSettings{User: "me", Password: "hunter2", Token: "tok", Hosts: []string{"a", "b"}}
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.User != "me" {
+		t.Fatalf("expected user to be kept, got %q", redacted.User)
+	}
+	if redacted.Password != redactedText || redacted.Token != redactedText {
+		t.Fatalf("expected secrets to be redacted, got %+v", redacted)
+	}
+	if redacted.Hosts[0] != redactedText {
+		t.Fatalf("expected sensitive list to be redacted, got %v", redacted.Hosts)
+	}
+	if cfg.Data().Password != "hunter2" {
+		t.Fatalf("expected loaded data to be untouched, got %+v", cfg.Data())
+	}
+
+	values, err := cfg.RedactedValues()
+	if err != nil {
+		t.Fatalf("RedactedValues failed: %v", err)
+	}
+	if values["password"] != redactedText || values["user"] != "me" {
+		t.Fatalf("unexpected redacted values: %v", values)
+	}
+
+	content, err := cfg.RedactedContent()
+	if err != nil {
+		t.Fatalf("RedactedContent failed: %v", err)
+	}
+	if strings.Contains(string(content), "hunter2") {
+		t.Fatalf("expected redacted content, got %s", content)
+	}
+}
+
+func TestRedactedContent(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t)
+	writeTestFile(t, cfg.Path(), `{"user":"me","password":"hunter2","match":[{"when":{"os":"*"},"set":{"password":"letmein"}}]}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	content, err := cfg.RedactedContent()
+	if err != nil {
+		t.Fatalf("RedactedContent failed: %v", err)
+	}
+	if strings.Contains(string(content), "hunter2") || strings.Contains(string(content), "letmein") {
+		t.Fatalf("expected redacted content, got %s", content)
+	}
+	if !strings.Contains(string(content), `"user": "me"`) || !strings.Contains(string(content), `"when"`) {
+		t.Fatalf("expected the rest of the file to be kept, got %s", content)
+	}
+
+	effective, err := cfg.RedactedEffectiveContent()
+	if err != nil {
+		t.Fatalf("RedactedEffectiveContent failed: %v", err)
+	}
+	if strings.Contains(string(effective), "letmein") || strings.Contains(string(effective), "match") {
+		t.Fatalf("expected redacted effective content, got %s", effective)
+	}
+}
+
+func TestRedactedQuarantined(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t)
+	quarantined := cfg.Path() + quarantineInfix + time.Now().Format(historyIDLayout)
+
+	writeTestFile(t, quarantined, "{\n  \"user\": \"me\",\n  \"password\": \"hunter2\",\n  \"token\": \"s3cr3t\",\n")
+	content, err := cfg.RedactedQuarantined("")
+	if err != nil {
+		t.Fatalf("RedactedQuarantined failed: %v", err)
+	}
+	want := "{\n  \"user\": \"me\",\n  \"password\": \"[REDACTED]\",\n  \"token\": \"[REDACTED]\",\n"
+	if string(content) != want {
+		t.Fatalf("expected corrupt content\n%s\ngot\n%s", want, content)
+	}
+
+	writeTestFile(t, quarantined, `{"user":"me","password":"hunter2","token":"s3cr3t"}`)
+	if content, err = cfg.RedactedQuarantined(""); err != nil {
+		t.Fatalf("RedactedQuarantined failed: %v", err)
+	}
+	if strings.Contains(string(content), "hunter2") || strings.Contains(string(content), "s3cr3t") || !strings.Contains(string(content), `"me"`) {
+		t.Fatalf("expected redacted content, got %s", content)
+	}
+}
+
+func TestRedactedDiff(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t, WithDefault(sensitiveSettings{User: "me", Password: "old-pass"}))
+	if err := cfg.Init(sensitiveSettings{User: "you", Password: "new-pass", Token: "tok"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	changes, err := cfg.RedactedDiffDefaults()
+	if err != nil {
+		t.Fatalf("RedactedDiffDefaults failed: %v", err)
+	}
+	want := []Change{
+		{Key: "password", Kind: ChangeModified, Old: redactedText, New: redactedText},
+		{Key: "token", Kind: ChangeModified, Old: "", New: redactedText},
+		{Key: "user", Kind: ChangeModified, Old: "me", New: "you"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected changes %+v, got %+v", want, changes)
+	}
+
+	changes, err = cfg.DiffDefaults()
+	if err != nil {
+		t.Fatalf("DiffDefaults failed: %v", err)
+	}
+	if changes[0].Old != "old-pass" || changes[0].New != "new-pass" {
+		t.Fatalf("expected revealed values, got %+v", changes[0])
+	}
+}
+
+func TestSecretFormatting(t *testing.T) {
+	secret := Secret("hunter2")
+	for _, format := range []string{"%v", "%s", "%+v", "%#v", "%q"} {
+		if out := fmt.Sprintf(format, secret); strings.Contains(out, "hunter2") {
+			t.Fatalf("format %s leaked secret: %s", format, out)
+		}
+	}
+
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("loaded", "token", secret, "settings", sensitiveSettings{Token: secret})
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatalf("log leaked secret: %s", buf.String())
+	}
+	if secret.Reveal() != "hunter2" {
+		t.Fatalf("expected Reveal to return the value")
+	}
+}
+
+func TestConfigFileLogging(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t)
+	if err := cfg.Init(sensitiveSettings{User: "me", Password: "hunter2"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if out := fmt.Sprintf("%+v", cfg); strings.Contains(out, "hunter2") || !strings.Contains(out, "me") {
+		t.Fatalf("unexpected formatted config: %s", out)
+	}
+
+	var buf strings.Builder
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("config", "cfg", cfg)
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatalf("log leaked secret: %s", buf.String())
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	cfg := mustNewSensitiveConfigFile(t, WithValidationPolicy[sensitiveSettings](ValidationOff))
+	if err := cfg.Init(sensitiveSettings{Password: "hunter2"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	original := cfg.Data().Validate()
+	err := cfg.RedactError(original)
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected redacted error, got %v", err)
+	}
+	if !errors.Is(err, original) {
+		t.Fatalf("expected redacted error to wrap the original")
+	}
+	if cfg.RedactError(nil) != nil {
+		t.Fatalf("expected nil for nil error")
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	t.Setenv("TEST_API_KEY", "from-env")
+
+	cfg := mustNewSecretConfigFile(t)
+	raw := fmt.Sprintf(`{"name":"refs","token":"${secret:file:%s}","api_key":"key=${secret:env:TEST_API_KEY}"}`, secretFile)
+	if err := os.MkdirAll(cfg.DirPath(), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte(raw), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got := cfg.Data(); got.Token != "from-file" || got.APIKey != "key=from-env" {
+		t.Fatalf("expected resolved secrets, got %+v", got)
+	}
+
+	data := cfg.Data()
+	data.Name = "changed"
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, err := cfg.Content()
+	if err != nil {
+		t.Fatalf("Content failed: %v", err)
+	}
+	if strings.Contains(string(content), "from-file") || strings.Contains(string(content), "from-env") {
+		t.Fatalf("resolved secrets written to disk: %s", content)
+	}
+	if !strings.Contains(string(content), "${secret:env:TEST_API_KEY}") || !strings.Contains(string(content), "changed") {
+		t.Fatalf("expected references and updates on disk, got %s", content)
+	}
+}
+
+func TestSecretReferenceUnknownProvider(t *testing.T) {
+	cfg := mustNewSecretConfigFile(t)
+	if err := os.MkdirAll(cfg.DirPath(), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte(`{"token":"${secret:cmd:echo hi}"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := cfg.Reload(); err == nil || !strings.Contains(err.Error(), "unknown secret provider") {
+		t.Fatalf("expected unknown provider error, got %v", err)
+	}
+
+	cfg = mustNewSecretConfigFile(t, WithExecSecretProvider[secretSettings]())
+	writeTestFile(t, cfg.Path(), `{"token":"${secret:cmd:echo hi}"}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg.Data().Token.Reveal(); got != "hi" {
+		t.Fatalf("expected the cmd provider to resolve the reference, got %q", got)
+	}
+}
+
+func TestSecretReferenceCustomProvider(t *testing.T) {
+	provider := SecretProviderFunc(func(ref string) (string, error) { return "vault:" + ref, nil })
+	cfg := mustNewSecretConfigFile(t, WithSecretProvider[secretSettings]("vault", provider))
+	if err := os.MkdirAll(cfg.DirPath(), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte(`{"extra":{"db":"${secret:vault:db/password}"}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg.Data().Extra["db"]; got != "vault:db/password" {
+		t.Fatalf("expected resolved value, got %q", got)
+	}
+}
+
+func TestExecSecretProvider(t *testing.T) {
+	value, err := ExecSecretProvider().Resolve("echo from-exec")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if value != "from-exec" {
+		t.Fatalf("expected from-exec, got %q", value)
+	}
+}
+
+func TestPatchDocumentKeepsOrder(t *testing.T) {
+	set := map[string]any{"b.inner": "patched", "d": 4}
+	remove := []string{"c"}
+
+	jsonOut, err := NewJSONFileManager[testSettings]().PatchDocument([]byte(`{"z":1,"b":{"inner":"x","keep":true},"c":3}`), set, remove)
+	if err != nil {
+		t.Fatalf("JSON PatchDocument failed: %v", err)
+	}
+	wantJSON := "{\n  \"z\": 1,\n  \"b\": {\n    \"inner\": \"patched\",\n    \"keep\": true\n  },\n  \"d\": 4\n}"
+	if string(jsonOut) != wantJSON {
+		t.Fatalf("unexpected JSON output:\n%s", jsonOut)
+	}
+
+	yamlOut, err := NewYAMLFileManager[testSettings]().PatchDocument([]byte("z: 1\nb:\n    inner: x # note\n    keep: true\nc: 3\n"), set, remove)
+	if err != nil {
+		t.Fatalf("YAML PatchDocument failed: %v", err)
+	}
+	wantYAML := "z: 1\nb:\n    inner: patched # note\n    keep: true\nd: 4\n"
+	if string(yamlOut) != wantYAML {
+		t.Fatalf("unexpected YAML output:\n%s", yamlOut)
+	}
+}
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("CFG_NAME", "svc")
+	t.Setenv("CFG_EMPTY", "")
+
+	cases := []struct {
+		in, want string
+	}{
+		{"name: ${CFG_NAME}", "name: svc"},
+		{"port: ${CFG_PORT_UNSET:-8080}", "port: 8080"},
+		{"port: ${CFG_EMPTY:-9090}", "port: 9090"},
+		{"price: $$5 and $${CFG_NAME}", "price: $5 and ${CFG_NAME}"},
+		{"token: ${secret:env:X}", "token: ${secret:env:X}"},
+		{"raw: $HOME ${unterminated", "raw: $HOME ${unterminated"},
+	}
+	for _, tc := range cases {
+		got, err := interpolateEnv([]byte(tc.in))
+		if err != nil {
+			t.Fatalf("interpolateEnv(%q) failed: %v", tc.in, err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("interpolateEnv(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := interpolateEnv([]byte("${CFG_REQUIRED:?must be set}")); err == nil || !strings.Contains(err.Error(), "must be set") {
+		t.Fatalf("expected required variable error, got %v", err)
+	}
+}
+
+func TestInterpolationPreservedOnWrite(t *testing.T) {
+	t.Setenv("CFG_NAME", "svc")
+	cfg, err := NewYAMLConfigFile(
+		WithPath[testSettings](t.TempDir()),
+		WithAppName[testSettings]("testapp"),
+		WithInterpolation[testSettings](),
+	)
+	if err != nil {
+		t.Fatalf("NewYAMLConfigFile failed: %v", err)
+	}
+	if err := os.MkdirAll(cfg.DirPath(), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	raw := "name: ${CFG_NAME}\nport: ${CFG_PORT:-8080}\n"
+	if err := os.WriteFile(cfg.Path(), []byte(raw), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "svc", Port: 8080}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Init(cfg.Data()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, _ := cfg.Content()
+	if string(content) != raw {
+		t.Fatalf("expected placeholders to be preserved, got %q", content)
+	}
+
+	if err := cfg.Init(testSettings{Name: "svc", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, _ = cfg.Content()
+	if want := "name: ${CFG_NAME}\nport: 1\n"; string(content) != want {
+		t.Fatalf("expected changed value to be written, got %q", content)
+	}
+}
+
+func TestInterpolationEscapesWrittenValues(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	t.Setenv("CFG_NAME", "svc")
+	for _, newConfigFile := range []func(...ConfigFileOption[testSettings]) (*ConfigFile[testSettings], error){
+		NewJSONConfigFile[testSettings],
+		NewYAMLConfigFile[testSettings],
+	} {
+		cfg, err := newConfigFile(
+			WithPath[testSettings](t.TempDir()),
+			WithAppName[testSettings]("testapp"),
+			WithInterpolation[testSettings](),
+		)
+		if err != nil {
+			t.Fatalf("new config file: %v", err)
+		}
+		if err := cfg.Init(testSettings{Port: 1}); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+
+		for _, name := range []string{"pa$$w${HOME}", "${CFG_NAME:-x} costs $5", "$"} {
+			if err := cfg.Set("name", name); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if err := cfg.Reload(); err != nil {
+				t.Fatalf("Reload failed: %v", err)
+			}
+			if got := cfg.Data().Name; got != name {
+				t.Fatalf("expected %q to read back unchanged, got %q", name, got)
+			}
+		}
+	}
+}
+
+func TestInterpolationDisabledByDefault(t *testing.T) {
+	t.Setenv("CFG_NAME", "svc")
+	cfg := mustNewTestConfigFile(t)
+	if err := os.MkdirAll(cfg.DirPath(), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte(`{"name":"${CFG_NAME}"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg.Data().Name; got != "${CFG_NAME}" {
+		t.Fatalf("expected raw value, got %q", got)
+	}
+}
+
+func TestIncludesAndDropIns(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	dir := cfg.DirPath()
+	writeTestFile(t, cfg.Path(), `{"name":"main","include":["extra/*.json"]}`)
+	writeTestFile(t, filepath.Join(dir, "extra", "a.json"), `{"name":"from-include","port":1}`)
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "10-port.json"), `{"port":10}`)
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "20-port.json"), `{"port":20}`)
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "ignored.yml"), `port: 30`)
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "from-include", Port: 20}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+
+	data := cfg.Data()
+	data.Name = "edited"
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, _ := cfg.Content()
+	var written map[string]any
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatalf("decode written file: %v", err)
+	}
+	if _, ok := written["port"]; ok {
+		t.Fatalf("expected drop-in value to stay out of the main file, got %s", content)
+	}
+	if written["name"] != "edited" || written["include"] == nil {
+		t.Fatalf("expected edit and include directive, got %s", content)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	dir := cfg.DirPath()
+
+	writeTestFile(t, cfg.Path(), `{"include":"missing.json"}`)
+	err := cfg.Reload()
+	if err == nil || !strings.Contains(err.Error(), cfg.Path()) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing include error citing %s, got %v", cfg.Path(), err)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"include":"a.json"}`)
+	writeTestFile(t, filepath.Join(dir, "a.json"), `{"include":"b.json"}`)
+	writeTestFile(t, filepath.Join(dir, "b.json"), `{"include":"a.json"}`)
+	err = cfg.Reload()
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	base := map[string]any{"a": 1, "nested": map[string]any{"x": 1, "y": 2}, "list": []any{1}}
+	overlay := map[string]any{"nested": map[string]any{"y": 3}, "list": []any{2}}
+
+	merged := mergeDocuments(base, overlay)
+	nested := merged["nested"].(map[string]any)
+	if merged["a"] != 1 || nested["x"] != 1 || nested["y"] != 3 {
+		t.Fatalf("unexpected merge result: %v", merged)
+	}
+	if list := merged["list"].([]any); len(list) != 1 || list[0] != 2 {
+		t.Fatalf("expected lists to be replaced, got %v", merged["list"])
+	}
+	if base["nested"].(map[string]any)["y"] != 2 {
+		t.Fatalf("expected base to be left untouched")
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	outer := t.TempDir()
+	repo := filepath.Join(outer, "repo")
+	sub := filepath.Join(repo, "sub")
+	work := filepath.Join(sub, "deeper")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatalf("create repo: %v", err)
+	}
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatalf("create work dir: %v", err)
+	}
+	writeTestFile(t, filepath.Join(outer, ".myapp.json"), `{"name":"outside-repo"}`)
+	writeTestFile(t, filepath.Join(repo, ".myapp.json"), `{"name":"repo","port":2}`)
+	writeTestFile(t, filepath.Join(sub, ".myapp.json"), `{"name":"sub"}`)
+	t.Chdir(work)
+
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "user", Port: 1}), WithDiscovery[testSettings](".myapp"))
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "sub", Port: 1}); got != want {
+		t.Fatalf("expected nearest file over user config %+v, got %+v", want, got)
+	}
+	if paths := cfg.DiscoveredPaths(); len(paths) != 1 || paths[0] != filepath.Join(sub, ".myapp.json") {
+		t.Fatalf("unexpected discovered paths: %v", paths)
+	}
+
+	all := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "user", Port: 1}), WithDiscovery[testSettings](".myapp"), WithDiscoverAll[testSettings]())
+	if err := all.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := all.Data(), (testSettings{Name: "sub", Port: 2}); got != want {
+		t.Fatalf("expected every file up to the git root %+v, got %+v", want, got)
+	}
+	if paths := all.DiscoveredPaths(); len(paths) != 2 || paths[0] != filepath.Join(repo, ".myapp.json") {
+		t.Fatalf("unexpected discovered paths: %v", paths)
+	}
+
+	content, _ := all.Content()
+	if strings.Contains(string(content), "sub") {
+		t.Fatalf("expected user config to keep its own values, got %s", content)
+	}
+}
+
+func TestDiscoveryRejectsUntrustedDirectives(t *testing.T) {
+	private := filepath.Join(t.TempDir(), "private")
+	writeTestFile(t, private, "top secret")
+
+	for name, content := range map[string]string{
+		"secret reference": fmt.Sprintf(`{"name":"${secret:file:%s}"}`, private),
+		"nested reference": fmt.Sprintf(`{"match":[{"when":{"os":"*"},"set":{"name":"${secret:file:%s}"}}]}`, private),
+		"include":          fmt.Sprintf(`{"include":%q}`, private),
+	} {
+		t.Run(name, func(t *testing.T) {
+			work := t.TempDir()
+			writeTestFile(t, filepath.Join(work, ".myapp.json"), content)
+			t.Chdir(work)
+
+			cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "user", Port: 1}), WithDiscovery[testSettings](".myapp"))
+			err := cfg.SoftInit()
+			if !errors.Is(err, ErrUntrustedProjectFile) {
+				t.Fatalf("expected ErrUntrustedProjectFile, got %v", err)
+			}
+			if strings.Contains(cfg.Data().Name, "top secret") {
+				t.Fatal("expected the private file not to be read")
+			}
+		})
+	}
+}
+
+func TestXDGDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "cfg"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("XDG_STATE_HOME", "relative/state")
+
+	checks := []struct {
+		name string
+		fn   func(string) (string, error)
+		want string
+	}{
+		{"config", ConfigDir, filepath.Join(home, "cfg", "myapp")},
+		{"data", DataDir, filepath.Join(home, "data", "myapp")},
+		{"cache", CacheDir, filepath.Join(home, "cache", "myapp")},
+		{"state", StateDir, filepath.Join(home, ".local", "state", "myapp")},
+	}
+	for _, check := range checks {
+		got, err := check.fn("myapp")
+		if err != nil {
+			t.Fatalf("%s dir failed: %v", check.name, err)
+		}
+		if got != check.want {
+			t.Fatalf("expected %s dir %q, got %q", check.name, check.want, got)
+		}
+	}
+
+	cfg, err := NewJSONConfigFile(WithAppName[testSettings]("myapp"))
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	if want := filepath.Join(home, "cfg", "myapp"); cfg.DirPath() != want {
+		t.Fatalf("expected config under XDG_CONFIG_HOME %q, got %q", want, cfg.DirPath())
+	}
+}
+
+func TestNoUserConfigDir(t *testing.T) {
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	if _, err := NewJSONConfigFile[testSettings](); !errors.Is(err, ErrNoUserDir) {
+		t.Fatalf("expected ErrNoUserDir, got %v", err)
+	}
+	if _, err := NewJSONConfigFile(WithPath[testSettings](t.TempDir())); err != nil {
+		t.Fatalf("expected explicit path to work without HOME, got %v", err)
+	}
+}
+
+func TestSystemConfigDirs(t *testing.T) {
+	system := t.TempDir()
+	vendor := t.TempDir()
+	t.Setenv("XDG_CONFIG_DIRS", strings.Join([]string{system, "relative", vendor}, string(os.PathListSeparator)))
+	writeTestFile(t, filepath.Join(system, "testapp", "config.json"), `{"name":"system"}`)
+	writeTestFile(t, filepath.Join(vendor, "testapp", "config.json"), `{"name":"vendor","port":7}`)
+
+	if dirs := SystemConfigDirs(); len(dirs) != 2 || dirs[0] != system || dirs[1] != vendor {
+		t.Fatalf("unexpected system dirs: %v", dirs)
+	}
+
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "default", Port: 1}))
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "system", Port: 7}); got != want {
+		t.Fatalf("expected system defaults %+v, got %+v", want, got)
+	}
+	content, _ := cfg.Content()
+	if strings.Contains(string(content), "system") || strings.Contains(string(content), "port") {
+		t.Fatalf("expected system values to stay out of the user file, got %s", content)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"port":9}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "system", Port: 9}); got != want {
+		t.Fatalf("expected user file over system defaults %+v, got %+v", want, got)
+	}
+
+	t.Setenv("XDG_CONFIG_DIRS", "")
+	if dirs := SystemConfigDirs(); len(dirs) != 1 || dirs[0] != "/etc/xdg" {
+		t.Fatalf("expected /etc/xdg by default, got %v", dirs)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if cfg.Profile() != DefaultProfile {
+		t.Fatalf("expected default profile, got %q", cfg.Profile())
+	}
+
+	if err := cfg.CreateProfile("dev"); err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	if err := cfg.CreateProfile("dev"); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+	writeTestFile(t, cfg.ProfilePath("dev"), `{"name":"dev","port":2}`)
+	if err := cfg.CopyProfile("dev", "qa"); err != nil {
+		t.Fatalf("CopyProfile failed: %v", err)
+	}
+	if profiles, _ := cfg.Profiles(); strings.Join(profiles, ",") != "default,dev,qa" {
+		t.Fatalf("unexpected profiles: %v", profiles)
+	}
+
+	if err := cfg.UseProfile("qa"); err != nil {
+		t.Fatalf("UseProfile failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "dev", Port: 2}); got != want {
+		t.Fatalf("expected copied profile %+v, got %+v", want, got)
+	}
+	if err := cfg.DeleteProfile("qa"); err == nil {
+		t.Fatal("expected deleting the active profile to fail")
+	}
+
+	reopened := mustNewTestConfigFile(t, WithPath[testSettings](filepath.Dir(cfg.DirPath())))
+	if reopened.Profile() != "qa" || reopened.Path() != cfg.ProfilePath("qa") {
+		t.Fatalf("expected persisted profile qa, got %q at %s", reopened.Profile(), reopened.Path())
+	}
+	t.Setenv("TESTAPP_PROFILE", "dev")
+	if reopened.Profile() != "dev" {
+		t.Fatalf("expected TESTAPP_PROFILE to override the marker, got %q", reopened.Profile())
+	}
+
+	if err := cfg.UseProfile(DefaultProfile); err != nil {
+		t.Fatalf("UseProfile failed: %v", err)
+	}
+	if err := cfg.DeleteProfile("qa"); err != nil {
+		t.Fatalf("DeleteProfile failed: %v", err)
+	}
+
+	missing := mustNewTestConfigFile(t, WithProfile[testSettings]("staging"))
+	if err := missing.SoftInit(); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if _, err := os.Stat(missing.Path()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing profile not to be created, got %v", err)
+	}
+}
+
+func TestProfileInheritance(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}), WithProfile[testSettings]("qa"))
+	writeTestFile(t, cfg.ProfilePath(DefaultProfile), `{"name":"base","port":1}`)
+	writeTestFile(t, cfg.ProfilePath("dev"), `{"port":2}`)
+	writeTestFile(t, cfg.ProfilePath("qa"), `{"extends":"dev","name":"qa"}`)
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "qa", Port: 2}); got != want {
+		t.Fatalf("expected inherited values %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Init(cfg.Data()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, _ := cfg.Content()
+	var written map[string]any
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatalf("decode written profile: %v", err)
+	}
+	if len(written) != 2 || written["extends"] != "dev" || written["name"] != "qa" {
+		t.Fatalf("expected only the profile's own keys to be written, got %s", content)
+	}
+
+	writeTestFile(t, cfg.ProfilePath("dev"), `{"extends":"qa"}`)
+	if err := cfg.Reload(); err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Fatalf("expected extends cycle error, got %v", err)
+	}
+
+	replace := mustNewTestConfigFile(t, WithProfile[testSettings]("dev"), WithProfileReplace[testSettings]())
+	writeTestFile(t, replace.ProfilePath(DefaultProfile), `{"name":"base","port":1}`)
+	writeTestFile(t, replace.ProfilePath("dev"), `{"port":2}`)
+	if err := replace.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := replace.Data(), (testSettings{Port: 2}); got != want {
+		t.Fatalf("expected profile to replace the base file %+v, got %+v", want, got)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+
+	fields := cfg.Fields()
+	if len(fields) != 2 || fields[0].Key != "name" || fields[1].Key != "port" || fields[1].Default != 1 {
+		t.Fatalf("unexpected fields: %+v", fields)
+	}
+
+	if err := cfg.SetOverrides(map[string]any{"missing": 1}); err == nil {
+		t.Fatal("expected unknown key to be rejected")
+	}
+	if err := cfg.SetOverrides(map[string]any{"port": "9"}); err == nil {
+		t.Fatal("expected mismatched type to be rejected")
+	}
+	if err := cfg.SetOverrides(map[string]any{"port": int64(9)}); err != nil {
+		t.Fatalf("SetOverrides failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "base", Port: 9}); got != want {
+		t.Fatalf("expected override over the file %+v, got %+v", want, got)
+	}
+
+	data := cfg.Data()
+	data.Name = "changed"
+	if err := cfg.Init(data); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	content, _ := cfg.Content()
+	if !strings.Contains(string(content), `"port": 1`) || !strings.Contains(string(content), "changed") {
+		t.Fatalf("expected the file to keep its own port, got %s", content)
+	}
+}
+
+func TestWithFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.yaml")
+	writeTestFile(t, path, "name: explicit\nport: 3\n")
+
+	cfg := mustNewTestConfigFile(t, WithFile[testSettings](path), WithProfile[testSettings]("missing"))
+	if cfg.Path() != path || cfg.DirPath() != dir {
+		t.Fatalf("expected explicit file %s, got %s in %s", path, cfg.Path(), cfg.DirPath())
+	}
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "explicit", Port: 3}); got != want {
+		t.Fatalf("expected YAML detected from the extension %+v, got %+v", want, got)
+	}
+
+	if _, err := NewJSONConfigFile(WithFile[testSettings](filepath.Join(dir, "config.toml"))); err == nil {
+		t.Fatal("expected unsupported extension to be rejected")
+	}
+	if err := cfg.SetFile(filepath.Join(dir, "other.json")); err != nil {
+		t.Fatalf("SetFile failed: %v", err)
+	}
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	content, _ := cfg.Content()
+	if !strings.HasPrefix(string(content), "{") {
+		t.Fatalf("expected JSON to be written to the .json file, got %s", content)
+	}
+}
+
+func TestLoadDoesNotCreateFile(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	if err := cfg.SetOverrides(map[string]any{"port": 5}); err != nil {
+		t.Fatalf("SetOverrides failed: %v", err)
+	}
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "base", Port: 5}); got != want {
+		t.Fatalf("expected defaults with overrides %+v, got %+v", want, got)
+	}
+	if exists, _ := cfg.Exists(); exists {
+		t.Fatal("expected Load not to create the configuration file")
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"file","port":2}`)
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "file", Port: 5}); got != want {
+		t.Fatalf("expected file with overrides %+v, got %+v", want, got)
+	}
+}
+
+func TestLoadWithoutFileLayers(t *testing.T) {
+	system := t.TempDir()
+	t.Setenv("XDG_CONFIG_DIRS", system)
+	writeTestFile(t, filepath.Join(system, "testapp", "config.json"), `{"name":"sys"}`)
+
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	writeTestFile(t, filepath.Join(cfg.DropInDir(), "10-port.json"), `{"port":8080}`)
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "sys", Port: 8080}); got != want {
+		t.Fatalf("expected the system and drop-in files over the defaults %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Set("port", 9); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if doc := readJSONFile(t, cfg.Path()); doc["name"] != nil || doc["port"] != float64(9) {
+		t.Fatalf("expected only the changed key in the file, got %v", doc)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	cfg := mustNewTestConfigFile(t,
+		WithDefault(testSettings{Name: "base", Port: 1}),
+		WithReadOnly[testSettings](),
+		WithRecovery[testSettings](),
+	)
+	if !cfg.ReadOnly() {
+		t.Fatal("expected ReadOnly to report true")
+	}
+	if err := cfg.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "base", Port: 1}); got != want {
+		t.Fatalf("expected defaults %+v, got %+v", want, got)
+	}
+	if exists, _ := cfg.Exists(); exists {
+		t.Fatal("expected SoftInit not to create the configuration file")
+	}
+
+	for name, op := range map[string]func() error{
+		"Init":          func() error { return cfg.Init(testSettings{Name: "x", Port: 2}) },
+		"Reset":         func() error { return cfg.Reset() },
+		"Rollback":      func() error { return cfg.Rollback("") },
+		"EncryptSecret": func() error { return cfg.EncryptSecrets() },
+		"CreateProfile": func() error { return cfg.CreateProfile("dev") },
+		"UseProfile":    func() error { return cfg.UseProfile("dev") },
+	} {
+		if err := op(); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+	if _, err := cfg.Backup(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Backup: expected ErrReadOnly, got %v", err)
+	}
+	if exists, _ := cfg.Exists(); exists {
+		t.Fatal("expected no file to be written")
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":`)
+	if err := cfg.Reload(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt without recovery, got %v", err)
+	}
+	if quarantined, _ := cfg.Quarantined(); len(quarantined) != 0 {
+		t.Fatalf("expected nothing quarantined, got %v", quarantined)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	if err := cfg.Init(testSettings{Name: "base", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"base","port":2}`)
+	err := cfg.Init(testSettings{Name: "mine", Port: 1})
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) {
+		t.Fatalf("expected a *ConflictError, got %v", err)
+	}
+	wantLocal := []Change{{Key: "name", Kind: ChangeModified, Old: "base", New: "mine"}}
+	if !reflect.DeepEqual(conflict.Local, wantLocal) {
+		t.Fatalf("expected local changes %+v, got %+v", wantLocal, conflict.Local)
+	}
+	wantDisk := []Change{{Key: "port", Kind: ChangeModified, Old: json.Number("1"), New: json.Number("2")}}
+	if !reflect.DeepEqual(conflict.Disk, wantDisk) {
+		t.Fatalf("expected disk changes %+v, got %+v", wantDisk, conflict.Disk)
+	}
+	if len(conflict.Keys) != 0 {
+		t.Fatalf("expected no conflicting keys, got %v", conflict.Keys)
+	}
+	if buf, _ := cfg.Content(); string(buf) != `{"name":"base","port":2}` {
+		t.Fatalf("expected the file to be left alone, got %s", buf)
+	}
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if err := cfg.Init(testSettings{Name: "mine", Port: 2}); err != nil {
+		t.Fatalf("expected a write after reloading to succeed, got %v", err)
+	}
+}
+
+func TestWriteAfterLoadWithoutFile(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := cfg.Set("name", "y"); err != nil {
+		t.Fatalf("expected Set after Load to create the file, got %v", err)
+	}
+	if got, want := readJSONFile(t, cfg.Path()), map[string]any{"name": "y", "port": float64(1)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected file %v, got %v", want, got)
+	}
+
+	other := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "base", Port: 1}))
+	if err := other.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := other.Init(testSettings{Name: "init", Port: 2}); err != nil {
+		t.Fatalf("expected Init after Load to create the file, got %v", err)
+	}
+}
+
+func TestConflictMerge(t *testing.T) {
+	cfg := mustNewTestConfigFile(t, WithConflictMerge[testSettings]())
+	if err := cfg.Init(testSettings{Name: "base", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"base","port":2}`)
+	if err := cfg.Init(testSettings{Name: "mine", Port: 1}); err != nil {
+		t.Fatalf("expected changes to be merged, got %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "mine", Port: 2}); got != want {
+		t.Fatalf("expected merged data %+v, got %+v", want, got)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"theirs","port":2}`)
+	err := cfg.Init(testSettings{Name: "ours", Port: 2})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a *ConflictError, got %v", err)
+	}
+	if want := []string{"name"}; !reflect.DeepEqual(conflict.Keys, want) {
+		t.Fatalf("expected conflicting keys %v, got %v", want, conflict.Keys)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	writeTestFile(t, policyPath, `{"port":9}`)
+	var warnings []error
+	cfg := mustNewTestConfigFile(t,
+		WithDefault(testSettings{Name: "base", Port: 1}),
+		WithPolicyFile[testSettings](policyPath),
+		WithWarningHandler[testSettings](func(err error) { warnings = append(warnings, err) }),
+	)
+
+	if err := cfg.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "base", Port: 9}); got != want {
+		t.Fatalf("expected defaults under the policy %+v, got %+v", want, got)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"user","port":2}`)
+	if err := cfg.SetOverrides(map[string]any{"port": 5}); err != nil {
+		t.Fatalf("SetOverrides failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "user", Port: 9}); got != want {
+		t.Fatalf("expected the policy to win %+v, got %+v", want, got)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrLocked) {
+		t.Fatalf("expected a warning about the locked override, got %v", warnings)
+	}
+
+	locked, err := cfg.LockedKeys()
+	if err != nil {
+		t.Fatalf("LockedKeys failed: %v", err)
+	}
+	if want := []string{"port"}; !reflect.DeepEqual(locked, want) {
+		t.Fatalf("expected locked keys %v, got %v", want, locked)
+	}
+
+	if err := cfg.Set("port", 3); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := cfg.Set("name", "changed"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set("missing", 1); err == nil {
+		t.Fatal("expected an unknown key to be rejected")
+	}
+
+	var onDisk testSettings
+	buf, _ := cfg.Content()
+	if err := json.Unmarshal(buf, &onDisk); err != nil {
+		t.Fatalf("decode file: %v", err)
+	}
+	if want := (testSettings{Name: "changed", Port: 2}); onDisk != want {
+		t.Fatalf("expected the file to keep the user's port %+v, got %+v", want, onDisk)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	public, private, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	parsed, err := ParsePublicKey([]byte(EncodeSigningKey(public)))
+	if err != nil || !parsed.Equal(public) {
+		t.Fatalf("expected the public key to round-trip, got %v (%v)", parsed, err)
+	}
+
+	cfg := mustNewTestConfigFile(t, WithTrustedKeys[testSettings](public))
+	writeTestFile(t, cfg.Path(), `{"name":"fleet","port":1}`)
+	if err := cfg.Reload(); !errors.Is(err, ErrNoSignature) {
+		t.Fatalf("expected ErrNoSignature, got %v", err)
+	}
+
+	if err := cfg.Sign(private); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := cfg.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"name":"tampered","port":1}`)
+	if err := cfg.Reload(); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+	if got := cfg.Data().Name; got != "fleet" {
+		t.Fatalf("expected the verified data to be kept, got %q", got)
+	}
+
+	other, _, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	if err := cfg.Sign(private); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := cfg.VerifyWith(other); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected an untrusted key to be rejected, got %v", err)
+	}
+
+	signing := mustNewTestConfigFile(t, WithTrustedKeys[testSettings](public), WithSigningKey[testSettings](private))
+	if err := signing.SoftInit(); err != nil {
+		t.Fatalf("SoftInit with a signing key failed: %v", err)
+	}
+	if err := signing.Init(testSettings{Name: "signed", Port: 2}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := signing.Reload(); err != nil {
+		t.Fatalf("expected writes to be signed, got %v", err)
+	}
+}
+
+func TestSignatureOfMergedFiles(t *testing.T) {
+	public, private, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	cfg := mustNewTestConfigFile(t, WithTrustedKeys[testSettings](public), WithSigningKey[testSettings](private))
+	include := filepath.Join(cfg.DirPath(), "extra.json")
+	dropIn := filepath.Join(cfg.DropInDir(), "10-port.json")
+	writeTestFile(t, include, `{"name":"included"}`)
+	writeTestFile(t, dropIn, `{"port":10}`)
+	if err := cfg.Init(testSettings{Name: "main"}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	writeTestFile(t, cfg.Path(), `{"include":"extra.json"}`)
+	if err := cfg.Sign(private); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	for _, path := range []string{include, dropIn} {
+		if err := cfg.Reload(); !errors.Is(err, ErrNoSignature) {
+			t.Fatalf("expected ErrNoSignature for %s, got %v", path, err)
+		}
+		if err := SignFile(path, private); err != nil {
+			t.Fatalf("SignFile failed: %v", err)
+		}
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "included", Port: 10}); got != want {
+		t.Fatalf("expected data %+v, got %+v", want, got)
+	}
+
+	writeTestFile(t, dropIn, `{"port":99}`)
+	if err := cfg.Reload(); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature for a modified drop-in, got %v", err)
+	}
+}
+
+func TestSignedRollback(t *testing.T) {
+	public, private, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey failed: %v", err)
+	}
+	cfg := mustNewTestConfigFile(t, WithTrustedKeys[testSettings](public))
+	for _, content := range []string{`{"name":"v1"}`, `{"name":"v2"}`} {
+		if _, err := cfg.Backup(); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		writeTestFile(t, cfg.Path(), content)
+		if err := cfg.Sign(private); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if err := cfg.Rollback(""); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload after Rollback failed: %v", err)
+	}
+	if got := cfg.Data().Name; got != "v1" {
+		t.Fatalf("expected v1, got %q", got)
+	}
+
+	entry, err := cfg.LookupHistory("")
+	if err != nil {
+		t.Fatalf("LookupHistory failed: %v", err)
+	}
+	writeTestFile(t, entry.Path, `{"name":"tampered"}`)
+	signing := mustNewTestConfigFile(t, WithPath[testSettings](cfg.path), WithTrustedKeys[testSettings](public), WithSigningKey[testSettings](private))
+	if err := signing.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if err := signing.Rollback(entry.ID); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected a tampered snapshot to be refused, got %v", err)
+	}
+	if err := signing.Reload(); err != nil || signing.Data().Name != "v1" {
+		t.Fatalf("expected the file to be unchanged, got %+v (%v)", signing.Data(), err)
+	}
+}
+
+func TestConditionalSections(t *testing.T) {
+	previous := hostname
+	hostname = func() (string, error) { return "Build-01", nil }
+	t.Cleanup(func() { hostname = previous })
+
+	cfg := mustNewTestConfigFile(t)
+	writeTestFile(t, cfg.Path(), fmt.Sprintf(`{"name":"base","port":1,"match":[
+		{"when":{"hostname":"build-*","os":[%q,"plan9"]},"set":{"port":9}},
+		{"when":{"hostname":"laptop"},"set":{"name":"laptop"}}
+	]}`, runtime.GOOS))
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "base", Port: 9}); got != want {
+		t.Fatalf("expected the matching section to apply %+v, got %+v", want, got)
+	}
+
+	if err := cfg.Set("name", "changed"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	var onDisk map[string]any
+	buf, _ := cfg.Content()
+	if err := json.Unmarshal(buf, &onDisk); err != nil {
+		t.Fatalf("decode file: %v", err)
+	}
+	if onDisk["port"] != float64(1) || onDisk["name"] != "changed" {
+		t.Fatalf("expected the base values to stay in the file, got %v", onDisk)
+	}
+	if _, ok := onDisk["match"]; !ok {
+		t.Fatalf("expected the conditional sections to be kept, got %v", onDisk)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"match":[{"when":{"kernel":"linux"},"set":{"port":2}}]}`)
+	if err := cfg.Reload(); err == nil || !strings.Contains(err.Error(), `unknown condition "kernel"`) {
+		t.Fatalf("expected an unknown condition error, got %v", err)
+	}
+}
+
+var hookCalls []string
+
+type hookSettings struct {
+	Dir   string `json:"dir"`
+	Level string `json:"level,omitempty"`
+	Depth int    `json:"-"`
+}
+
+func (h hookSettings) Validate() error {
+	hookCalls = append(hookCalls, "Validate")
+	return nil
+}
+
+func (h *hookSettings) SetDefaults() {
+	hookCalls = append(hookCalls, "SetDefaults")
+	if h.Level == "" {
+		h.Level = "info"
+	}
+}
+
+func (h *hookSettings) Normalize() error {
+	hookCalls = append(hookCalls, "Normalize")
+	if rest, ok := strings.CutPrefix(h.Dir, "~/"); ok {
+		h.Dir = "/home/test/" + rest
+	}
+	return nil
+}
+
+func (h *hookSettings) AfterLoad() error {
+	hookCalls = append(hookCalls, "AfterLoad")
+	h.Depth = strings.Count(h.Dir, "/")
+	return nil
+}
+
+func (h *hookSettings) BeforeSave() error {
+	hookCalls = append(hookCalls, "BeforeSave")
+	if h.Level == "info" {
+		h.Level = ""
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	t.Cleanup(func() { hookCalls = nil })
+	cfg, err := NewJSONConfigFile(
+		WithPath[hookSettings](t.TempDir()),
+		WithAppName[hookSettings]("testapp"),
+	)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"dir":"~/data"}`)
+	hookCalls = nil
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if want := []string{"SetDefaults", "Normalize", "Validate", "AfterLoad"}; !reflect.DeepEqual(hookCalls, want) {
+		t.Fatalf("expected load hooks %v, got %v", want, hookCalls)
+	}
+	if got, want := cfg.Data(), (hookSettings{Dir: "/home/test/data", Level: "info", Depth: 3}); got != want {
+		t.Fatalf("expected prepared data %+v, got %+v", want, got)
+	}
+
+	hookCalls = nil
+	if err := cfg.Set("level", "debug"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if want := []string{"Validate", "BeforeSave"}; !reflect.DeepEqual(hookCalls, want) {
+		t.Fatalf("expected save hooks %v, got %v", want, hookCalls)
+	}
+	if got, want := readJSONFile(t, cfg.Path()), map[string]any{"dir": "~/data", "level": "debug"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the normalized path to stay unexpanded on disk %v, got %v", want, got)
+	}
+
+	if err := cfg.Set("level", "info"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got, want := readJSONFile(t, cfg.Path()), map[string]any{"dir": "~/data"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected BeforeSave to clean up the written copy %v, got %v", want, got)
+	}
+	if got, want := cfg.Data(), (hookSettings{Dir: "/home/test/data", Level: "info", Depth: 3}); got != want {
+		t.Fatalf("expected the in-memory data to be left alone %+v, got %+v", want, got)
+	}
+}
+
+type renamedSettings struct {
+	Server struct {
+		Address string `json:"address" alias:"listen_addr, addr"`
+	} `json:"server"`
+	Mode string `json:"mode,omitempty" deprecated:"modes were removed"`
+}
+
+func (renamedSettings) Validate() error { return nil }
+
+func TestDeprecatedKeys(t *testing.T) {
+	var warnings []error
+	newConfig := func(dir string, opts ...ConfigFileOption[renamedSettings]) *ConfigFile[renamedSettings] {
+		options := append([]ConfigFileOption[renamedSettings]{
+			WithPath[renamedSettings](dir),
+			WithAppName[renamedSettings]("testapp"),
+			WithWarningHandler[renamedSettings](func(err error) { warnings = append(warnings, err) }),
+		}, opts...)
+		cfg, err := NewJSONConfigFile(options...)
+		if err != nil {
+			t.Fatalf("NewJSONConfigFile failed: %v", err)
+		}
+		return cfg
+	}
+
+	cfg := newConfig(t.TempDir())
+	writeTestFile(t, cfg.Path(), `{"listen_addr":":80","mode":"fast"}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg.Data().Server.Address; got != ":80" {
+		t.Fatalf("expected the former key to be used, got %q", got)
+	}
+	var deprecation *Deprecation
+	if len(warnings) != 2 || !errors.As(warnings[0], &deprecation) || deprecation.Key != "listen_addr" || deprecation.Replacement != "server.address" {
+		t.Fatalf("expected warnings about listen_addr and mode, got %v", warnings)
+	}
+
+	lint, err := cfg.Lint()
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	if len(lint) != 2 || lint[0].Key != "listen_addr" || lint[1].Key != "mode" || lint[1].Message != "modes were removed" {
+		t.Fatalf("expected lint to list listen_addr and mode, got %v", lint)
+	}
+
+	if err := cfg.Set("mode", "slow"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got := readJSONFile(t, cfg.Path())
+	if _, ok := lookupKey(got, "server.address"); ok || got["listen_addr"] != ":80" {
+		t.Fatalf("expected the file to keep the former key, got %v", got)
+	}
+	if err := cfg.Set("server.address", ":90"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got, want := readJSONFile(t, cfg.Path()), map[string]any{"server": map[string]any{"address": ":90"}, "mode": "slow"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected a changed value to use the current key %v, got %v", want, got)
+	}
+
+	writeTestFile(t, cfg.Path(), `{"addr":":70","server":{"address":":90"}}`)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg.Data().Server.Address; got != ":90" {
+		t.Fatalf("expected the current key to win, got %q", got)
+	}
+	if lint, _ := cfg.Lint(); len(lint) != 1 || !lint[0].Ignored {
+		t.Fatalf("expected the former key to be reported as ignored, got %v", lint)
+	}
+
+	rewrite := newConfig(t.TempDir(), WithAliasRewrite[renamedSettings]())
+	writeTestFile(t, rewrite.Path(), `{"listen_addr":":80"}`)
+	if err := rewrite.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if err := rewrite.Set("mode", "slow"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got, want := readJSONFile(t, rewrite.Path()), map[string]any{"server": map[string]any{"address": ":80"}, "mode": "slow"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the file to be rewritten in the new shape %v, got %v", want, got)
+	}
+}
+
+func TestValidationPolicy(t *testing.T) {
+	newStrict := func(opts ...ConfigFileOption[strictSettings]) *ConfigFile[strictSettings] {
+		options := append([]ConfigFileOption[strictSettings]{
+			WithPath[strictSettings](t.TempDir()),
+			WithAppName[strictSettings]("testapp"),
+		}, opts...)
+		cfg, err := NewJSONConfigFile(options...)
+		if err != nil {
+			t.Fatalf("NewJSONConfigFile failed: %v", err)
+		}
+		return cfg
+	}
+
+	strict := newStrict()
+	var invalid *ValidationError
+	if err := strict.Init(strictSettings{Name: "bad", Port: -1}); !errors.As(err, &invalid) {
+		t.Fatalf("expected ValidationError on write, got %v", err)
+	}
+	if exists, _ := strict.Exists(); exists {
+		t.Fatal("expected invalid data not to be written")
+	}
+	if err := strict.Init(strictSettings{Name: "good", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	writeTestFile(t, strict.Path(), `{"name":"bad","port":-1}`)
+	if err := strict.Reload(); !errors.As(err, &invalid) || invalid.Path != strict.Path() {
+		t.Fatalf("expected ValidationError on reload, got %v", err)
+	}
+	if got := strict.Data(); got.Name != "good" {
+		t.Fatalf("expected previous good data to be kept, got %+v", got)
+	}
+
+	var warnings []error
+	warn := newStrict(WithValidationPolicy[strictSettings](ValidationWarnOnly), WithWarningHandler[strictSettings](func(err error) {
+		warnings = append(warnings, err)
+	}))
+	if err := warn.Init(strictSettings{Name: "bad", Port: -1}); err != nil {
+		t.Fatalf("expected WarnOnly to accept invalid data, got %v", err)
+	}
+	if err := warn.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(warnings) != 2 || !errors.As(warnings[0], &invalid) {
+		t.Fatalf("expected a warning per load and write, got %v", warnings)
+	}
+
+	off := newStrict(WithValidationPolicy[strictSettings](ValidationOff), WithWarningHandler[strictSettings](func(err error) {
+		t.Fatalf("unexpected warning with validation off: %v", err)
+	}))
+	if err := off.Init(strictSettings{Name: "bad", Port: -1}); err != nil {
+		t.Fatalf("expected validation to be skipped, got %v", err)
+	}
+	writeTestFile(t, filepath.Join(off.HistoryDir(), "config.json.20000101T000000.000000000"), `{"name":"old","port":-2}`)
+	if err := off.Rollback(""); err != nil {
+		t.Fatalf("expected Rollback to skip validation, got %v", err)
+	}
+	if got := off.Data(); got.Name != "old" {
+		t.Fatalf("expected the snapshot to be restored, got %+v", got)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	plain := mustNewTestConfigFile(t)
+	writeTestFile(t, plain.Path(), `{"name":`)
+	if err := plain.Reload(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt without recovery, got %v", err)
+	}
+
+	var reports []error
+	cfg := mustNewTestConfigFile(t,
+		WithDefault(testSettings{Name: "default"}),
+		WithRecovery[testSettings](),
+		WithWarningHandler[testSettings](func(err error) { reports = append(reports, err) }),
+	)
+	if err := cfg.Init(testSettings{Name: "first", Port: 1}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := cfg.Init(testSettings{Name: "second", Port: 2}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	writeTestFile(t, cfg.Path(), `{"name":`)
+
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got, want := cfg.Data(), (testSettings{Name: "first", Port: 1}); got != want {
+		t.Fatalf("expected newest snapshot %+v, got %+v", want, got)
+	}
+	var report *Recovery
+	if len(reports) != 1 || !errors.As(reports[0], &report) || report.RestoredFrom == "" {
+		t.Fatalf("expected a recovery report, got %v", reports)
+	}
+	quarantined, err := cfg.Quarantined()
+	if err != nil || len(quarantined) != 1 || quarantined[0].Path != report.Quarantined {
+		t.Fatalf("unexpected quarantined files: %v, %v", quarantined, err)
+	}
+
+	if err := cfg.RestoreQuarantined(""); err != nil {
+		t.Fatalf("RestoreQuarantined failed: %v", err)
+	}
+	if content, _ := cfg.Content(); string(content) != `{"name":` {
+		t.Fatalf("expected quarantined content back in place, got %s", content)
+	}
+	if _, err := cfg.LookupQuarantined(""); !errors.Is(err, ErrQuarantineNotFound) {
+		t.Fatalf("expected no quarantined files left, got %v", err)
+	}
+
+	fresh := mustNewTestConfigFile(t, WithDefault(testSettings{Name: "default"}), WithRecovery[testSettings](), WithWarningHandler[testSettings](func(error) {}))
+	writeTestFile(t, fresh.Path(), ``)
+	if err := fresh.SoftInit(); err != nil {
+		t.Fatalf("SoftInit failed: %v", err)
+	}
+	if got := fresh.Data(); got.Name != "default" {
+		t.Fatalf("expected defaults without history, got %+v", got)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cfg := mustNewTestConfigFile(t)
+	writeTestFile(t, cfg.Path(), "{\n  \"name\": \"x\",\n  \"port\": }\n")
+
+	err := cfg.Reload()
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if perr.Path != cfg.Path() || perr.Line != 3 || perr.Column != 11 || perr.Format != "JSON" {
+		t.Fatalf("unexpected parse error position: %+v", perr)
+	}
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected syntax errors to be reported as ErrCorrupt, got %v", err)
+	}
+	if want := fmt.Sprintf("%s:3:11: invalid JSON", cfg.Path()); !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected %q in %q", want, err.Error())
+	}
+
+	yamlPath := filepath.Join(t.TempDir(), "config.yml")
+	writeTestFile(t, yamlPath, "name: x\nport: [\n")
+	var data testSettings
+	err = NewYAMLFileManager[testSettings]().LoadDataFromFile(yamlPath, &data)
+	if !errors.As(err, &perr) || perr.Line != 2 || perr.Format != "YAML" || perr.Path == "" {
+		t.Fatalf("unexpected YAML parse error: %#v", err)
+	}
+}
+
+func newTestConfigFile(t *testing.T) *ConfigFile[testSettings] {
+	t.Helper()
+	return &ConfigFile[testSettings]{
+		fileManager: NewJSONFileManager[testSettings](),
+		fileName:    "config.json",
+		appName:     "app",
+		path:        t.TempDir(),
+	}
+}
+
+func mustNewTestConfigFile(t *testing.T, opts ...ConfigFileOption[testSettings]) *ConfigFile[testSettings] {
+	t.Helper()
+	tempPath := t.TempDir()
+	options := []ConfigFileOption[testSettings]{
+		WithPath[testSettings](tempPath),
+		WithAppName[testSettings]("testapp"),
+	}
+	options = append(options, opts...)
+
+	cfg, err := NewJSONConfigFile(options...)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	return cfg
+}
+
+func mustNewSecretConfigFile(t *testing.T, opts ...ConfigFileOption[secretSettings]) *ConfigFile[secretSettings] {
+	t.Helper()
+	options := []ConfigFileOption[secretSettings]{
+		WithPath[secretSettings](t.TempDir()),
+		WithAppName[secretSettings]("testapp"),
+	}
+	options = append(options, opts...)
+
+	cfg, err := NewJSONConfigFile(options...)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	return cfg
+}
+
+func mustNewSensitiveConfigFile(t *testing.T, opts ...ConfigFileOption[sensitiveSettings]) *ConfigFile[sensitiveSettings] {
+	t.Helper()
+	key, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+	options := []ConfigFileOption[sensitiveSettings]{
+		WithPath[sensitiveSettings](t.TempDir()),
+		WithAppName[sensitiveSettings]("testapp"),
+		WithSecretKey[sensitiveSettings](key),
+	}
+	options = append(options, opts...)
+
+	cfg, err := NewJSONConfigFile(options...)
+	if err != nil {
+		t.Fatalf("NewJSONConfigFile failed: %v", err)
+	}
+	return cfg
+}
+
+func readJSONFile(t *testing.T, path string) map[string]any {
+	t.Helper()
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return doc
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
 }
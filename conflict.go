@@ -0,0 +1,205 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrConflict is returned when the configuration file changed on disk after
+// it was loaded and writing would overwrite those changes.
+var ErrConflict = errors.New("config: configuration file changed on disk")
+
+// ConflictError reports that the configuration file changed on disk since it
+// was loaded. It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	// Path is the configuration file.
+	Path string
+	// Local lists the changes about to be written, relative to the file as
+	// it was loaded.
+	Local []Change
+	// Disk lists the changes made to the file on disk since it was loaded.
+	Disk []Change
+	// Keys lists the keys changed differently on both sides. It is empty
+	// when the changes could be merged.
+	Keys []string
+}
+
+func (e *ConflictError) Error() string {
+	msg := fmt.Sprintf("%s: configuration file changed on disk since it was loaded (%d local, %d on disk)", e.Path, len(e.Local), len(e.Disk))
+	if len(e.Keys) > 0 {
+		msg += ": conflicting keys " + strings.Join(e.Keys, ", ")
+	}
+	return msg
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// WithConflictMerge makes writes merge concurrent changes instead of failing
+// with ErrConflict when the file changed on disk since it was loaded. Keys
+// changed only on one side are taken from that side; keys changed
+// differently on both sides still fail with a *ConflictError. A merged file
+// is reformatted and reloaded.
+func WithConflictMerge[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.conflictMerge = true
+	}
+}
+
+// fileState records the configuration file as it was loaded or written, to
+// detect changes made by someone else before the next write.
+type fileState struct {
+	path    string
+	exists  bool
+	size    int64
+	modTime time.Time
+	sum     [sha256.Size]byte
+	// doc is the decoded file, the base of a three-way merge.
+	doc map[string]any
+}
+
+// readFileState reads the state of the configuration file at path. A missing
+// file yields a state with exists set to false.
+func (c *ConfigFile[T]) readFileState(path string) (*fileState, []byte, error) {
+	state := &fileState{path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("check configuration file: %w", err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read configuration file: %w", err)
+	}
+	state.exists = true
+	state.size = info.Size()
+	state.modTime = info.ModTime()
+	state.sum = sha256.Sum256(buf)
+	return state, buf, nil
+}
+
+// currentState returns the state of the configuration file, to be recorded
+// as the one the in-memory data corresponds to. Files that cannot be decoded
+// have no merge base.
+func (c *ConfigFile[T]) currentState() (*fileState, error) {
+	state, buf, err := c.readFileState(c.Path())
+	if err != nil {
+		return nil, err
+	}
+	if state.exists {
+		state.doc, _ = c.fileManager.UnmarshalDocument(buf)
+	}
+	return state, nil
+}
+
+// resolveConflict compares the configuration file on disk with the state
+// recorded when it was loaded. When it is unchanged buf is returned as is.
+// Otherwise it fails with a *ConflictError, or, with WithConflictMerge,
+// returns buf merged with the changes on disk and reports merged as true.
+func (c *ConfigFile[T]) resolveConflict(buf []byte) (resolved []byte, merged bool, err error) {
+	loaded := c.state
+	if loaded == nil || loaded.path != c.Path() {
+		return buf, false, nil
+	}
+	current, disk, err := c.readFileState(loaded.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if current.exists == loaded.exists && (!current.exists ||
+		(current.size == loaded.size && current.modTime.Equal(loaded.modTime)) ||
+		current.sum == loaded.sum) {
+		return buf, false, nil
+	}
+
+	base := loaded.doc
+	if base == nil {
+		base = map[string]any{}
+	}
+	theirs := map[string]any{}
+	if current.exists {
+		if theirs, err = c.fileManager.UnmarshalDocument(disk); err != nil {
+			withPath(err, loaded.path)
+			return nil, false, fmt.Errorf("read changed configuration file: %w", err)
+		}
+	}
+	ours, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conflict := &ConflictError{
+		Path:  loaded.path,
+		Local: Diff(base, ours),
+		Disk:  Diff(base, theirs),
+	}
+	conflict.Keys = conflictingKeys(conflict.Local, conflict.Disk)
+	if !c.conflictMerge || len(conflict.Keys) > 0 {
+		return nil, false, conflict
+	}
+
+	for _, change := range conflict.Local {
+		if change.Kind == ChangeRemoved {
+			deleteKey(theirs, change.Key)
+			continue
+		}
+		setKey(theirs, change.Key, change.New)
+	}
+	if resolved, err = c.fileManager.MarshalDocument(theirs); err != nil {
+		return nil, false, fmt.Errorf("merge configuration file: %w", err)
+	}
+	return resolved, true, nil
+}
+
+// conflictingKeys returns the keys changed on both sides with different
+// results, including keys where one side changed a parent of the other.
+func conflictingKeys(local, disk []Change) []string {
+	changed := make(map[string]Change, len(disk))
+	for _, change := range disk {
+		changed[change.Key] = change
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, ours := range local {
+		for key, theirs := range changed {
+			if key == ours.Key {
+				if ours.Kind == theirs.Kind && reflect.DeepEqual(ours.New, theirs.New) {
+					continue
+				}
+			} else if !strings.HasPrefix(key, ours.Key+".") && !strings.HasPrefix(ours.Key, key+".") {
+				continue
+			}
+			if !seen[ours.Key] {
+				seen[ours.Key] = true
+				keys = append(keys, ours.Key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateMerged decodes a merged file and validates it according to the
+// validation policy before it is written.
+func (c *ConfigFile[T]) validateMerged(buf []byte) error {
+	var data T
+	if err := c.fileManager.Unmarshal(buf, &data); err != nil {
+		return fmt.Errorf("decode merged configuration: %w", err)
+	}
+	if err := c.decryptSecrets(&data); err != nil {
+		return fmt.Errorf("decode merged configuration: %w", err)
+	}
+	return c.validate(data, c.Path())
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// aliasTag is the struct tag that lists former dotted keys of a field,
+	// separated by commas, e.g. `alias:"listen_addr"`.
+	aliasTag = "alias"
+	// deprecatedTag is the struct tag that marks a field as deprecated; its
+	// value tells users what to do instead.
+	deprecatedTag = "deprecated"
+)
+
+// Deprecation reports a deprecated or renamed key found in a configuration
+// file. It is sent to the warning handler while loading and returned by
+// Lint.
+type Deprecation struct {
+	// Path is the file that uses the key.
+	Path string
+	// Key is the dotted key found in the file.
+	Key string
+	// Replacement is the key that replaces it, if any.
+	Replacement string
+	// Message is the text of the field's deprecated tag.
+	Message string
+	// Ignored reports that the key was not used because its replacement is
+	// also set.
+	Ignored bool
+}
+
+func (d *Deprecation) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: key %q is deprecated", d.Path, d.Key)
+	switch {
+	case d.Ignored:
+		fmt.Fprintf(&b, " and ignored because %q is also set", d.Replacement)
+	case d.Replacement != "":
+		fmt.Fprintf(&b, "; use %q", d.Replacement)
+	}
+	if d.Message != "" {
+		fmt.Fprintf(&b, ": %s", d.Message)
+	}
+	return b.String()
+}
+
+// WithAliasRewrite writes values loaded from a former key, declared with the
+// alias tag, under their current key the next time the file is saved. By
+// default the file keeps the former key as long as the value is unchanged.
+func WithAliasRewrite[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.aliasRewrite = true
+	}
+}
+
+// Lint lists the deprecated and renamed keys used by the configuration
+// file, in the order the fields are declared.
+func (c *ConfigFile[T]) Lint() ([]*Deprecation, error) {
+	buf, err := c.Content()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		withPath(err, c.Path())
+		return nil, fmt.Errorf("lint configuration file: %w", err)
+	}
+	return c.migrateKeys(c.Path(), doc, nil), nil
+}
+
+// migrateKeys moves the values of former keys in doc, the document read from
+// path, to their current keys, and reports them together with deprecated
+// keys. A current key that is already set wins over its former keys. When
+// preserved is not nil the former keys are recorded there so the file keeps
+// them, unless WithAliasRewrite is enabled.
+func (c *ConfigFile[T]) migrateKeys(path string, doc map[string]any, preserved map[string]preservedValue) []*Deprecation {
+	found := make([]*Deprecation, 0)
+	for _, field := range c.Fields() {
+		if field.Deprecated != "" {
+			if _, ok := lookupKey(doc, field.Key); ok {
+				found = append(found, &Deprecation{Path: path, Key: field.Key, Message: field.Deprecated})
+			}
+		}
+		for _, alias := range field.Aliases {
+			value, ok := lookupKey(doc, alias)
+			if !ok {
+				continue
+			}
+			deleteKey(doc, alias)
+			deprecation := &Deprecation{Path: path, Key: alias, Replacement: field.Key}
+			found = append(found, deprecation)
+
+			if _, ok := lookupKey(doc, field.Key); ok {
+				deprecation.Ignored = true
+				if preserved != nil && !c.aliasRewrite {
+					preserved[alias] = preservedValue{raw: value, inFile: true, keep: true}
+				}
+				continue
+			}
+			setKey(doc, field.Key, value)
+			if preserved != nil && !c.aliasRewrite {
+				preserved[field.Key] = preservedValue{raw: value, loaded: value, inFile: true, alias: alias}
+			}
+		}
+	}
+	return found
+}
+
+// fieldAliases splits the value of an alias tag.
+func fieldAliases(tag string) []string {
+	aliases := make([]string, 0)
+	for _, alias := range strings.Split(tag, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind classifies a single key-level difference between two
+// configuration documents.
+type ChangeKind string
+
+const (
+	// ChangeAdded marks a key that only exists in the newer document.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved marks a key that only exists in the older document.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified marks a key whose value differs between both documents.
+	ChangeModified ChangeKind = "changed"
+)
+
+// Change describes how one dotted key differs between two documents.
+type Change struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+	Old  any        `json:"old,omitempty"`
+	New  any        `json:"new,omitempty"`
+}
+
+// Diff compares two generic documents and reports the key-level changes
+// required to go from the from document to the to document. Nested mappings
+// are flattened into dotted keys while lists are compared as whole values.
+// The result is sorted by key.
+func Diff(from, to map[string]any) []Change {
+	before := flattenDocument(from)
+	after := flattenDocument(to)
+
+	changes := make([]Change, 0)
+	for key, oldValue := range before {
+		newValue, ok := after[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: key, Kind: ChangeRemoved, Old: oldValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			changes = append(changes, Change{Key: key, Kind: ChangeModified, Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, Change{Key: key, Kind: ChangeAdded, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// DiffDefaults reports how the loaded configuration differs from the default
+// value configured with WithDefault.
+func (c *ConfigFile[T]) DiffDefaults() ([]Change, error) {
+	return c.diffAgainst(c.defaultData, false)
+}
+
+// RedactedDiffDefaults is like DiffDefaults but with sensitive values masked.
+func (c *ConfigFile[T]) RedactedDiffDefaults() ([]Change, error) {
+	return c.diffAgainst(c.defaultData, true)
+}
+
+// DiffFile reports how the loaded configuration differs from the file at
+// path. The file is decoded into the configuration type with the same format
+// as the managed file and layered like it, so the system, drop-in, policy,
+// and override values count on both sides and encrypted secrets are compared
+// by their values.
+func (c *ConfigFile[T]) DiffFile(path string) ([]Change, error) {
+	return c.diffFile(path, false)
+}
+
+// RedactedDiffFile is like DiffFile but with sensitive values masked.
+// Secrets are still compared by their values, so a changed secret is
+// reported without showing either value.
+func (c *ConfigFile[T]) RedactedDiffFile(path string) ([]Change, error) {
+	return c.diffFile(path, true)
+}
+
+// DiffEnvironment reports how the loaded configuration differs from the
+// variant selected for env (e.g. config.dev.yml for "dev").
+func (c *ConfigFile[T]) DiffEnvironment(env string) ([]Change, error) {
+	return c.diffFile(c.environmentPath(env), false)
+}
+
+// RedactedDiffEnvironment is like DiffEnvironment but with sensitive values
+// masked.
+func (c *ConfigFile[T]) RedactedDiffEnvironment(env string) ([]Change, error) {
+	return c.diffFile(c.environmentPath(env), true)
+}
+
+func (c *ConfigFile[T]) environmentPath(env string) string {
+	return filepath.Join(c.DirPath(), environmentFileName(c.fileName, env))
+}
+
+func (c *ConfigFile[T]) diffFile(path string, redact bool) ([]Change, error) {
+	data, _, err := c.readData(path, true)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := c.diffAgainst(data, redact)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %w", path, err)
+	}
+	return changes, nil
+}
+
+// diffAgainst compares base with the loaded configuration. When redact is
+// true the changes are computed from the real values and then given the
+// values of the redacted documents, so sensitive values never show.
+func (c *ConfigFile[T]) diffAgainst(base T, redact bool) ([]Change, error) {
+	from, err := c.document(base)
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	to, err := c.document(c.data)
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	changes := Diff(from, to)
+	if !redact {
+		return changes, nil
+	}
+
+	if from, err = c.document(c.redact(base)); err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	if to, err = c.document(c.redact(c.data)); err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	before, after := flattenDocument(from), flattenDocument(to)
+	for i, change := range changes {
+		if change.Kind != ChangeAdded {
+			changes[i].Old = maskedValue(before, change.Key)
+		}
+		if change.Kind != ChangeRemoved {
+			changes[i].New = maskedValue(after, change.Key)
+		}
+	}
+	return changes, nil
+}
+
+// RedactChanges returns a copy of changes with the values of secret and
+// sensitive settings masked, like the Redacted diff variants do. It is meant
+// for changes reported by other means, such as those of a *ConflictError.
+func (c *ConfigFile[T]) RedactChanges(changes []Change) []Change {
+	from, to := make(map[string]any), make(map[string]any)
+	for _, change := range changes {
+		if change.Kind != ChangeAdded {
+			setKey(from, change.Key, change.Old)
+		}
+		if change.Kind != ChangeRemoved {
+			setKey(to, change.Key, change.New)
+		}
+	}
+	before, after := c.maskedDocument(from), c.maskedDocument(to)
+
+	redacted := make([]Change, len(changes))
+	copy(redacted, changes)
+	for i, change := range redacted {
+		if value, ok := before[change.Key]; ok && change.Kind != ChangeAdded {
+			redacted[i].Old = value
+		}
+		if value, ok := after[change.Key]; ok && change.Kind != ChangeRemoved {
+			redacted[i].New = value
+		}
+	}
+	return redacted
+}
+
+// maskedDocument returns the redacted form of every value of doc that
+// redaction changes, keyed by dotted path. When doc cannot be decoded into
+// the configuration type every value is masked, as it cannot be told which
+// ones are sensitive.
+func (c *ConfigFile[T]) maskedDocument(doc map[string]any) map[string]any {
+	masked := make(map[string]any)
+	data, err := c.decodeDocument(doc)
+	var plain, redacted map[string]any
+	if err == nil {
+		plain, err = c.document(data)
+	}
+	if err == nil {
+		redacted, err = c.document(c.redact(data))
+	}
+	if err != nil {
+		for key := range flattenDocument(doc) {
+			masked[key] = redactedText
+		}
+		return masked
+	}
+	values := flattenDocument(redacted)
+	for key, value := range flattenDocument(plain) {
+		if redactedValue := maskedValue(values, key); !reflect.DeepEqual(value, redactedValue) {
+			masked[key] = redactedValue
+		}
+	}
+	return masked
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/vekio/x/fs"
+)
+
+// ErrUntrustedProjectFile is returned when a discovered project file uses a
+// directive that could read files or run commands on the user's behalf.
+var ErrUntrustedProjectFile = errors.New("config: directive not allowed in a project file")
+
+// WithDiscovery enables project-local configuration discovery. Starting at
+// the working directory and walking up to the filesystem root, or to the
+// first directory containing .git, the loader looks for a file called name
+// followed by the managed file's extension (e.g. .myapp.yml) and merges the
+// nearest one over the user configuration. Use WithDiscoverAll to merge every
+// file found instead. Project files come with whatever repository the
+// application runs in, so they may not include other files or use
+// ${secret:...} references; loading fails with ErrUntrustedProjectFile when
+// they do.
+func WithDiscovery[T Validatable](name string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(name)
+		if trimmed == "" {
+			return
+		}
+		c.discoveryName = trimmed
+	}
+}
+
+// WithDiscoverAll makes discovery merge every project file found while
+// walking up, farthest first, so nearer files win.
+func WithDiscoverAll[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.discoveryAll = true
+	}
+}
+
+// DiscoveredPaths returns the project files merged by the last load, in
+// merge order.
+func (c *ConfigFile[T]) DiscoveredPaths() []string {
+	return slices.Clone(c.discovered)
+}
+
+// discover walks up from the working directory and returns the project
+// files to merge, farthest first.
+func (c *ConfigFile[T]) discover() ([]string, error) {
+	if c.discoveryName == "" {
+		return nil, nil
+	}
+
+	fileName := c.discoveryName
+	if ext := c.fileManager.Extension(); !strings.HasSuffix(fileName, ext) {
+		fileName += ext
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("discover project configuration: %w", err)
+	}
+
+	var found []string
+	for {
+		candidate := filepath.Join(dir, fileName)
+		exists, err := fs.Exists(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("discover project configuration: %w", err)
+		}
+		if exists && candidate != c.Path() {
+			found = append(found, candidate)
+			if !c.discoveryAll {
+				break
+			}
+		}
+
+		isGitRoot, err := fs.Exists(filepath.Join(dir, ".git"))
+		if err != nil {
+			return nil, fmt.Errorf("discover project configuration: %w", err)
+		}
+		parent := filepath.Dir(dir)
+		if isGitRoot || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	slices.Reverse(found)
+	return found, nil
+}
+
+// loadProjectFile reads a discovered project file, rejecting the include
+// directive and secret references. stack holds the chain of files being
+// merged.
+func (c *ConfigFile[T]) loadProjectFile(path string, stack []string) (map[string]any, error) {
+	doc, err := c.readLayer(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := doc[includeKey]; ok {
+		return nil, fmt.Errorf("%s: %w: %s", path, ErrUntrustedProjectFile, includeKey)
+	}
+	if containsSecretRef(doc) {
+		return nil, fmt.Errorf("%s: %w: secret reference", path, ErrUntrustedProjectFile)
+	}
+	return c.mergeIncludes(path, doc, append(slices.Clone(stack), absPath(path)))
+}
+
+// containsSecretRef reports whether a ${secret:...} reference appears in any
+// string of value.
+func containsSecretRef(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return secretRefPattern.MatchString(v)
+	case map[string]any:
+		for _, item := range v {
+			if containsSecretRef(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if containsSecretRef(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// document converts a typed value into a generic document by encoding and
+// decoding it with the configured file manager, so keys match the ones
+// written to disk.
+func (c *ConfigFile[T]) document(data T) (map[string]any, error) {
+	buf, err := c.fileManager.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.fileManager.UnmarshalDocument(buf)
+}
+
+// decodeDocument converts a generic document back into the typed
+// configuration value. Decoding errors carry no position, as the document
+// may have been merged from several files.
+func (c *ConfigFile[T]) decodeDocument(doc map[string]any) (T, error) {
+	var data T
+	buf, err := c.fileManager.MarshalDocument(doc)
+	if err != nil {
+		return data, err
+	}
+	if err := c.fileManager.Unmarshal(buf, &data); err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Line, perr.Column = 0, 0
+		}
+		return data, err
+	}
+	return data, nil
+}
+
+// clone returns a deep copy of data made by encoding and decoding it with
+// the configured file manager.
+func (c *ConfigFile[T]) clone(data T) (T, error) {
+	var copied T
+	buf, err := c.fileManager.Marshal(data)
+	if err != nil {
+		return copied, err
+	}
+	if err := c.fileManager.Unmarshal(buf, &copied); err != nil {
+		return copied, err
+	}
+	return copied, nil
+}
+
+// preservedValue remembers the on-disk form of a key whose value was
+// rewritten while loading, such as a resolved secret reference or a value
+// merged from an included file.
+type preservedValue struct {
+	// raw is the value stored in the file.
+	raw any
+	// loaded is the value the key held after loading.
+	loaded any
+	// inFile reports whether the key exists in the file at all. Unchanged
+	// keys that do not are left out when writing.
+	inFile bool
+	// keep writes raw back regardless of the current data; it is used for
+	// directives that the configuration type does not model.
+	keep bool
+	// alias is the former key the value was read from. While the value is
+	// unchanged it is written back under that key instead.
+	alias string
+}
+
+// recordPreserved stores in preserved every key whose value differs between
+// the document read from disk and the document produced by loading it,
+// including keys that only exist after loading. Keys recorded by an earlier
+// loading step keep their on-disk form and only update the loaded value.
+func recordPreserved(preserved map[string]preservedValue, fileDoc, loadedDoc map[string]any) {
+	raw := flattenDocument(fileDoc)
+	for key, value := range flattenDocument(loadedDoc) {
+		if existing, ok := preserved[key]; ok {
+			existing.loaded = value
+			preserved[key] = existing
+			continue
+		}
+		rawValue, inFile := raw[key]
+		if inFile && reflect.DeepEqual(rawValue, value) {
+			continue
+		}
+		preserved[key] = preservedValue{raw: rawValue, loaded: value, inFile: inFile}
+	}
+}
+
+// restorePreserved patches the encoded form of data so keys that still hold
+// the value produced while loading are written back in their on-disk form,
+// or left out when they do not exist in the file.
+func (c *ConfigFile[T]) restorePreserved(data T, buf []byte) ([]byte, error) {
+	if len(c.preserved) == 0 {
+		return buf, nil
+	}
+
+	doc, err := c.document(data)
+	if err != nil {
+		return nil, err
+	}
+	current := flattenDocument(doc)
+
+	set := make(map[string]any)
+	var remove []string
+	for key, value := range c.preserved {
+		if value.keep {
+			set[key] = value.raw
+			continue
+		}
+		currentValue, ok := current[key]
+		if !ok || !reflect.DeepEqual(currentValue, value.loaded) {
+			continue
+		}
+		if value.alias != "" {
+			set[value.alias] = value.raw
+			remove = append(remove, key)
+			continue
+		}
+		if value.inFile {
+			set[key] = value.raw
+		} else {
+			remove = append(remove, key)
+		}
+	}
+	if len(set) == 0 && len(remove) == 0 {
+		return buf, nil
+	}
+	return c.fileManager.PatchDocument(buf, set, remove)
+}
+
+// mergeDocuments deep-merges overlay into a copy of base. Nested mappings are
+// merged key by key; any other overlay value replaces the base value.
+func mergeDocuments(base, overlay map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		baseMap, baseIsMap := merged[key].(map[string]any)
+		overlayMap, overlayIsMap := value.(map[string]any)
+		if baseIsMap && overlayIsMap {
+			merged[key] = mergeDocuments(baseMap, overlayMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// flattenDocument turns nested mappings into a single level map keyed by
+// dotted paths.
+func flattenDocument(doc map[string]any) map[string]any {
+	flat := make(map[string]any)
+	flattenInto(flat, "", doc)
+	return flat
+}
+
+func flattenInto(flat map[string]any, prefix string, doc map[string]any) {
+	for key, value := range doc {
+		path := joinKey(prefix, key)
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			flattenInto(flat, path, nested)
+			continue
+		}
+		flat[path] = value
+	}
+}
+
+// lookupKey returns the value stored under the dotted key.
+func lookupKey(doc map[string]any, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	current := doc
+	for i, part := range parts {
+		value, ok := current[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return value, true
+		}
+		nested, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = nested
+	}
+	return nil, false
+}
+
+// setKey stores value under the dotted key, creating intermediate mappings
+// as needed.
+func setKey(doc map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := current[part].(map[string]any)
+		if !ok {
+			nested = map[string]any{}
+			current[part] = nested
+		}
+		current = nested
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// deleteKey removes the dotted key from doc when present.
+func deleteKey(doc map[string]any, key string) {
+	parts := strings.Split(key, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := current[part].(map[string]any)
+		if !ok {
+			return
+		}
+		current = nested
+	}
+	delete(current, parts[len(parts)-1])
+}
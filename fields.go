@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fieldWalker visits the leaf values of a configuration value together with
+// the dotted document key they are encoded under.
+type fieldWalker struct {
+	// tag is the struct tag that names document keys ("json" or "yaml").
+	tag string
+	// marks selects the struct fields whose leaves are visited; values of
+	// type Secret are always visited.
+	marks func(reflect.StructField) bool
+	// visit is called for every settable marked leaf.
+	visit func(key string, value reflect.Value) error
+}
+
+// walk descends into v, whose document key is key. marked reports whether
+// an enclosing field was selected by marks.
+func (w fieldWalker) walk(v reflect.Value, key string, marked bool) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return w.walk(v.Elem(), key, marked)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, inline, skip := fieldKey(field, w.tag)
+			if skip {
+				continue
+			}
+			fieldPath := key
+			if !inline {
+				fieldPath = joinKey(key, name)
+			}
+			if err := w.walk(v.Field(i), fieldPath, marked || w.marks(field)); err != nil {
+				return fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.walk(v.Index(i), fmt.Sprintf("%s[%d]", key, i), marked); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, mapKey := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(mapKey))
+			if err := w.walk(elem, joinKey(key, fmt.Sprint(mapKey.Interface())), marked); err != nil {
+				return err
+			}
+			v.SetMapIndex(mapKey, elem)
+		}
+	default:
+		if (marked || v.Type() == secretType) && v.CanSet() {
+			return w.visit(key, v)
+		}
+	}
+	return nil
+}
+
+// fieldKey returns the document key a struct field is encoded under for the
+// given struct tag, whether the field is inlined into its parent, and whether
+// it is skipped entirely.
+func fieldKey(field reflect.StructField, tag string) (name string, inline, skip bool) {
+	value := field.Tag.Get(tag)
+	if value == "-" {
+		return "", false, true
+	}
+
+	name, options, _ := strings.Cut(value, ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "inline" {
+			return "", true, false
+		}
+	}
+	if name == "" && field.Anonymous && tag == "json" && field.Type.Kind() == reflect.Struct {
+		return "", true, false
+	}
+
+	if name == "" {
+		name = field.Name
+		if tag == "yaml" {
+			name = strings.ToLower(name)
+		}
+	}
+	return name, false, false
+}
+
+// joinKey appends name to the dotted key prefix.
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// structTag returns the struct tag used by the configured file manager to
+// name document keys.
+func (c *ConfigFile[T]) structTag() string {
+	if c.fileManager.Extension() == ".json" {
+		return "json"
+	}
+	return "yaml"
+}
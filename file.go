@@ -1,6 +1,9 @@
 package config
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,12 +16,49 @@ import (
 // ConfigFile wraps the metadata and helpers required to manage one
 // application-specific configuration file.
 type ConfigFile[T Validatable] struct {
-	fileManager FileManager[T]
+	fileManager documentManager[T]
 	fileName    string
 	path        string
 	appName     string
 	data        T
 	defaultData T
+
+	historyRetention int
+
+	secretKey     []byte
+	secretKeyFile string
+	secretKeyEnv  string
+
+	secretProviders map[string]SecretProvider
+	preserved       map[string]preservedValue
+	interpolate     bool
+
+	discoveryName string
+	discoveryAll  bool
+	discovered    []string
+
+	profile        string
+	profileReplace bool
+
+	overrides map[string]any
+
+	file string
+
+	validationPolicy ValidationPolicy
+	warningHandler   func(error)
+	recovery         bool
+
+	readOnly bool
+
+	state         *fileState
+	conflictMerge bool
+
+	policyFile string
+
+	trustedKeys []ed25519.PublicKey
+	signingKey  ed25519.PrivateKey
+
+	aliasRewrite bool
 }
 
 // Validatable is implemented by configuration types that can perform their own
@@ -27,19 +67,55 @@ type Validatable interface {
 	Validate() error
 }
 
+// AppName returns the application identifier used to locate the
+// configuration directory and to name environment variables.
+func (c *ConfigFile[T]) AppName() string {
+	return c.appName
+}
+
 // DirPath returns the full directory path where the application's configuration files are stored.
 // It combines the configuration directory and the application's name.
 func (c *ConfigFile[T]) DirPath() string {
+	if c.file != "" {
+		return filepath.Dir(c.file)
+	}
 	return filepath.Join(c.path, c.appName)
 }
 
 // Path constructs and returns the full path to the configuration file.
-// It combines the directory path and the file name.
+// It combines the directory path and the file name of the active profile.
+// Without a profile, the legacy <APP>_ENV variant is used when it exists.
+// A file set with WithFile or SetFile is returned as is.
 func (c *ConfigFile[T]) Path() string {
+	if c.file != "" {
+		return c.file
+	}
+	if profile := c.Profile(); profile != DefaultProfile {
+		return c.ProfilePath(profile)
+	}
 	fileName := getFileNameForEnvironment(c.DirPath(), c.appName, c.fileName)
 	return filepath.Join(c.DirPath(), fileName)
 }
 
+// SetFile points the ConfigFile at an explicit file, detecting the format
+// from its extension. Profiles and environment variants no longer apply.
+// Call Reload or SoftInit afterwards to load it.
+func (c *ConfigFile[T]) SetFile(path string) error {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return fmt.Errorf("config: file path must not be empty")
+	}
+	manager, err := fileManagerFor[T](path)
+	if err != nil {
+		return err
+	}
+
+	c.file = filepath.Clean(path)
+	c.fileName = filepath.Base(c.file)
+	c.fileManager = manager
+	return nil
+}
+
 // Content reads and returns the content of the configuration file.
 // It returns an error if the file cannot be read.
 func (c *ConfigFile[T]) Content() ([]byte, error) {
@@ -50,56 +126,354 @@ func (c *ConfigFile[T]) Content() ([]byte, error) {
 	return buf, nil
 }
 
+// EffectiveContent encodes the loaded configuration, with every layer,
+// matching conditional section, policy, and override applied, in the format
+// of the configuration file. Secret values are in plain text.
+func (c *ConfigFile[T]) EffectiveContent() ([]byte, error) {
+	buf, err := c.fileManager.Marshal(c.data)
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return buf, nil
+}
+
 // Data returns the in-memory copy of the configuration that was last
 // loaded from disk or passed to Init/SoftInit.
 func (c *ConfigFile[T]) Data() T {
 	return c.data
 }
 
+// DefaultData returns the default configuration value configured with
+// WithDefault.
+func (c *ConfigFile[T]) DefaultData() T {
+	return c.defaultData
+}
+
 // Reload refreshes the cached configuration by pulling the latest content
-// from disk using the configured file manager.
+// from disk using the configured file manager. It returns ErrProfileNotFound
+// when the active profile has no file. Under ValidationStrict, content that
+// fails validation is rejected and the previously loaded data is kept. A
+// corrupt file is reported as ErrCorrupt unless WithRecovery is enabled.
+// The file's state is recorded so later writes can detect changes made to it
+// in the meantime.
 func (c *ConfigFile[T]) Reload() error {
-	if err := c.fileManager.LoadDataFromFile(c.Path(), &c.data); err != nil {
-		return fmt.Errorf("load configuration file: %w", err)
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
+	state, err := c.currentState()
+	if err != nil {
+		return err
 	}
+	data, preserved, err := c.readData(c.Path(), true)
+	if err != nil && c.recovery && !c.readOnly && errors.Is(err, ErrCorrupt) {
+		if err := c.recover(err); err != nil {
+			return err
+		}
+		if state, err = c.currentState(); err != nil {
+			return err
+		}
+		data, preserved, err = c.readData(c.Path(), true)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.validate(data, c.Path()); err != nil {
+		return err
+	}
+	if err := afterLoad(&data); err != nil {
+		return err
+	}
+	c.data = data
+	c.preserved = preserved
+	c.state = state
 	return nil
 }
 
-// Init initializes the configuration by ensuring that the directory and file exist,
-// and by writing the initial configuration data to the file.
+// Init initializes the configuration by ensuring that the directory exists
+// and by writing the initial configuration data to the file. The data is
+// validated first according to the validation policy. When history is
+// enabled the previous file is saved to the history directory first. It
+// fails with a *ConflictError when the file changed on disk since it was
+// loaded, unless WithConflictMerge is enabled.
 func (c *ConfigFile[T]) Init(data T) error {
-	if err := fs.EnsureDir(c.DirPath(), fs.DefaultDirMode); err != nil {
-		return fmt.Errorf("ensure config directory: %w", err)
+	return c.save(data, true)
+}
+
+// save validates and writes data like Init. The previous file is only saved
+// to the history when backup is true.
+func (c *ConfigFile[T]) save(data T, backup bool) error {
+	if err := c.checkWritable("init configuration file"); err != nil {
+		return err
 	}
-	if err := file.Touch(c.Path(), fs.DefaultFileMode); err != nil {
-		return fmt.Errorf("ensure config file: %w", err)
+	if err := c.validate(data, c.Path()); err != nil {
+		return err
 	}
-	c.data = data
-
-	if err := c.fileManager.WriteDataToFile(c.Path(), data); err != nil {
-		return fmt.Errorf("write configuration file: %w", err)
+	if backup && c.historyRetention > 0 {
+		if _, err := c.Backup(); err != nil {
+			return err
+		}
 	}
-	return nil
+	if err := fs.EnsureDir(c.DirPath(), fs.DefaultDirMode); err != nil {
+		return fmt.Errorf("ensure config directory: %w", err)
+	}
+	return c.writeData(data, true)
 }
 
 // SoftInit attempts to initialize the configuration by loading existing data or creating new configuration.
-// It reads the configuration if the file exists or initializes it if it does not.
+// It initializes the file with the defaults if it does not exist and then reads it, so files layered over
+// it are applied in both cases. Keys set by a system configuration file in $XDG_CONFIG_DIRS are left out
+// of the new file so later changes to the system defaults still apply. A missing profile other than the
+// default one is reported as ErrProfileNotFound instead of being created. A read-only ConfigFile behaves
+// like Load.
 func (c *ConfigFile[T]) SoftInit() error {
+	if c.readOnly {
+		return c.Load()
+	}
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
 	exists, err := file.Exists(c.Path())
 	if err != nil {
 		return fmt.Errorf("check configuration file: %w", err)
 	}
 	if !exists {
-		return c.Init(c.defaultData)
+		if err := c.initFromSystem(); err != nil {
+			return err
+		}
+	}
+
+	return c.Reload()
+}
+
+// Load reads the configuration file when it exists and otherwise uses the
+// defaults, layered like the file would be, without creating anything on
+// disk.
+func (c *ConfigFile[T]) Load() error {
+	if err := c.checkProfile(); err != nil {
+		return err
+	}
+	exists, err := c.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return c.Reload()
+	}
+	state, err := c.currentState()
+	if err != nil {
+		return err
+	}
+
+	defaults, err := c.document(c.defaultData)
+	if err != nil {
+		return fmt.Errorf("load defaults: %w", err)
+	}
+	system, err := c.systemDocument()
+	if err != nil {
+		return fmt.Errorf("load defaults: %w", err)
 	}
+	preserved := make(map[string]preservedValue)
+	data, err := c.layerData(c.Path(), nil, mergeDocuments(defaults, system), nil, true, preserved)
+	if err != nil {
+		return fmt.Errorf("load defaults: %w", err)
+	}
+	if err := c.keepOut(system, data, preserved); err != nil {
+		return fmt.Errorf("load defaults: %w", err)
+	}
+	if err := c.validate(data, c.Path()); err != nil {
+		return err
+	}
+	if err := afterLoad(&data); err != nil {
+		return err
+	}
+	c.data = data
+	c.preserved = preserved
+	c.state = state
+	return nil
+}
+
+// readData loads and decodes the file at path, verifying its signature and
+// those of the files merged into it when trusted keys are configured,
+// interpolating environment variables when enabled, moving renamed keys to
+// their current names, layering it over the system configuration and the
+// profiles it inherits from, merging included, drop-in, and discovered files
+// and then the policy file, decrypting secret fields, resolving secret
+// references, applying overrides to keys the policy does not lock, and running
+// the SetDefaults and Normalize hooks. It also returns the keys whose on-disk
+// form must be preserved when the data is written back. The system, drop-in,
+// discovered, and policy files and the overrides are only applied when main is
+// true, that is, when path holds the configuration file or a snapshot of it.
+func (c *ConfigFile[T]) readData(path string, main bool) (T, map[string]preservedValue, error) {
+	var data T
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return data, nil, fmt.Errorf("load configuration file: %w", err)
+	}
+	if path == c.Path() {
+		if err := c.verifyLayer(path, buf); err != nil {
+			return data, nil, fmt.Errorf("load configuration file: %w", err)
+		}
+	}
+
+	preserved := make(map[string]preservedValue)
+	if c.interpolate {
+		if buf, err = c.interpolateDocument(buf, preserved); err != nil {
+			return data, nil, fmt.Errorf("load configuration file: %w", err)
+		}
+	}
+
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		withPath(err, path)
+		return data, nil, fmt.Errorf("load configuration file: %w: %w", ErrCorrupt, err)
+	}
+	deprecations := c.migrateKeys(path, doc, preserved)
+	for _, deprecation := range deprecations {
+		c.warn(deprecation)
+	}
+	var base map[string]any
+	if main {
+		if base, err = c.systemDocument(); err != nil {
+			return data, nil, fmt.Errorf("load configuration file: %w", err)
+		}
+	}
+	// A migrated document no longer matches buf.
+	if len(deprecations) > 0 {
+		buf = nil
+	}
+	if data, err = c.layerData(path, buf, doc, base, main, preserved); err != nil {
+		return data, nil, fmt.Errorf("load configuration file: %w", err)
+	}
+	return data, preserved, nil
+}
+
+// layerData decodes doc, the document read from path, over base and the
+// profiles it inherits from, merging included, drop-in, and discovered files
+// and then the policy file, decrypting secret fields, resolving secret
+// references, applying overrides, and running the SetDefaults and Normalize
+// hooks. The drop-in, discovered, and policy files and the overrides are
+// only applied when main is true. buf is the encoded doc, decoded directly
+// when nothing is merged into it, or nil to decode doc instead.
+func (c *ConfigFile[T]) layerData(path string, buf []byte, doc, base map[string]any, main bool, preserved map[string]preservedValue) (T, error) {
+	var (
+		data     T
+		overlays []string
+	)
+	inherited, err := c.inheritedDocument(path, doc, preserved)
+	if err != nil {
+		return data, err
+	}
+	if inherited != nil {
+		base = mergeDocuments(base, inherited)
+	}
+	if main {
+		if overlays, err = c.overlayFiles(); err != nil {
+			return data, err
+		}
+	}
+	layered, err := c.layerDocument(path, base, doc, overlays, preserved)
+	if err != nil {
+		return data, err
+	}
+	var policy map[string]any
+	if main {
+		if policy, err = c.policyDocument(); err != nil {
+			return data, err
+		}
+		layered = applyPolicy(policy, doc, layered, preserved)
+	}
+	if layered == nil && buf == nil {
+		layered = doc
+	}
+	if layered != nil {
+		data, err = c.decodeDocument(layered)
+	} else if err = c.fileManager.Unmarshal(buf, &data); err != nil {
+		withPath(err, path)
+		if len(bytes.TrimSpace(buf)) == 0 {
+			err = fmt.Errorf("%w: %w", ErrCorrupt, err)
+		}
+	}
+	if err != nil {
+		return data, err
+	}
+	if err := c.decryptSecrets(&data); err != nil {
+		return data, err
+	}
+	if err := c.resolveSecretRefs(&data, preserved); err != nil {
+		return data, err
+	}
+	if main {
+		if err := c.applyOverrides(&data, doc, policy, preserved); err != nil {
+			return data, err
+		}
+	}
+	if err := c.prepareData(&data, doc, preserved); err != nil {
+		return data, err
+	}
+	return data, nil
+}
 
-	if err := c.fileManager.LoadDataFromFile(c.Path(), &c.data); err != nil {
-		return fmt.Errorf("load configuration file: %w", err)
+// writeData persists data to the configuration file and makes it the loaded
+// data, running the BeforeSave hook on the copy that is written and
+// encrypting secret fields unless encrypt is false. Values that were
+// derived while loading, such as resolved secret references, are written
+// back in their original form as long as they were not changed. Changes made
+// to the file since it was loaded are reported or merged by resolveConflict;
+// a merged file is reloaded.
+func (c *ConfigFile[T]) writeData(data T, encrypt bool) error {
+	saved, err := c.beforeSave(data)
+	if err != nil {
+		return fmt.Errorf("write configuration file: %w", err)
+	}
+	encoded := saved
+	if encrypt {
+		encrypted, err := c.encryptSecrets(saved)
+		if err != nil {
+			return fmt.Errorf("write configuration file: %w", err)
+		}
+		encoded = encrypted
+	}
+
+	buf, err := c.fileManager.Marshal(encoded)
+	if err != nil {
+		return fmt.Errorf("write configuration file: %w", err)
+	}
+	if c.interpolate {
+		if buf, err = c.escapeDocument(buf); err != nil {
+			return fmt.Errorf("write configuration file: %w", err)
+		}
+	}
+	if buf, err = c.restorePreserved(saved, buf); err != nil {
+		return fmt.Errorf("write configuration file: %w", err)
+	}
+	buf, merged, err := c.resolveConflict(buf)
+	if err != nil {
+		return fmt.Errorf("write configuration file: %w", err)
+	}
+	if merged {
+		if err := c.validateMerged(buf); err != nil {
+			return fmt.Errorf("write configuration file: %w", err)
+		}
+	}
+	if err := fs.WriteFileWithDirs(c.Path(), buf, fs.RestrictedFileMode); err != nil {
+		return fmt.Errorf("write configuration file: %w", err)
+	}
+	if err := c.signWritten(buf); err != nil {
+		return err
 	}
+	if merged {
+		return c.Reload()
+	}
+	if c.state, err = c.currentState(); err != nil {
+		return err
+	}
+	c.data = data
 	return nil
 }
 
-// getFileNameForEnvironment
+// getFileNameForEnvironment returns the environment-specific variant of
+// configFileName selected by the <APP>_ENV variable when that file exists in
+// dirPath, and configFileName otherwise.
 func getFileNameForEnvironment(dirPath, appName, configFileName string) string {
 	envVarName := fmt.Sprintf("%s_ENV", strings.ToUpper(appName))
 	envValue := strings.TrimSpace(os.Getenv(envVarName))
@@ -107,17 +481,7 @@ func getFileNameForEnvironment(dirPath, appName, configFileName string) string {
 		return configFileName
 	}
 
-	base := strings.TrimSuffix(filepath.Base(configFileName), filepath.Ext(configFileName))
-	rawExt := filepath.Ext(configFileName)
-	extension := strings.TrimPrefix(rawExt, ".")
-	lowerEnv := strings.ToLower(envValue)
-	var candidateFileName string
-	if extension == "" {
-		candidateFileName = fmt.Sprintf("%s.%s", base, lowerEnv)
-	} else {
-		candidateFileName = fmt.Sprintf("%s.%s.%s", base, lowerEnv, extension)
-	}
-
+	candidateFileName := environmentFileName(configFileName, envValue)
 	candidatePath := filepath.Join(dirPath, candidateFileName)
 	exists, err := file.Exists(candidatePath)
 	if err != nil {
@@ -129,3 +493,15 @@ func getFileNameForEnvironment(dirPath, appName, configFileName string) string {
 
 	return configFileName
 }
+
+// environmentFileName inserts the lower-cased environment name between the
+// base name and the extension of configFileName (config.yml -> config.dev.yml).
+func environmentFileName(configFileName, env string) string {
+	base := strings.TrimSuffix(filepath.Base(configFileName), filepath.Ext(configFileName))
+	extension := strings.TrimPrefix(filepath.Ext(configFileName), ".")
+	lowerEnv := strings.ToLower(strings.TrimSpace(env))
+	if extension == "" {
+		return fmt.Sprintf("%s.%s", base, lowerEnv)
+	}
+	return fmt.Sprintf("%s.%s.%s", base, lowerEnv, extension)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vekio/x/fs"
+)
+
+const (
+	// historyDirName is the directory under DirPath that stores snapshots.
+	historyDirName = ".history"
+	// historyIDLayout formats snapshot identifiers; it sorts chronologically.
+	historyIDLayout = "20060102T150405.000000000"
+	// defaultHistoryRetention is the number of snapshots kept per file.
+	defaultHistoryRetention = 10
+)
+
+// ErrHistoryNotFound is returned when a requested snapshot does not exist.
+var ErrHistoryNotFound = errors.New("config: history entry not found")
+
+// HistoryEntry describes one snapshot of the configuration file.
+type HistoryEntry struct {
+	ID   string    `json:"id"`
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+// WithHistory sets how many snapshots of the configuration file are kept in
+// the .history directory. Every write through Init stores the previous file
+// first. A retention of zero disables automatic snapshots.
+func WithHistory[T Validatable](retention int) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil || retention < 0 {
+			return
+		}
+		c.historyRetention = retention
+	}
+}
+
+// HistoryDir returns the directory where configuration snapshots are kept.
+func (c *ConfigFile[T]) HistoryDir() string {
+	return filepath.Join(c.DirPath(), historyDirName)
+}
+
+// History lists the stored snapshots of the configuration file, newest first.
+func (c *ConfigFile[T]) History() ([]HistoryEntry, error) {
+	entries, err := os.ReadDir(c.HistoryDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history directory: %w", err)
+	}
+
+	prefix := filepath.Base(c.Path()) + "."
+	history := make([]HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(name, prefix)
+		stamp, err := time.ParseInLocation(historyIDLayout, id, time.Local)
+		if err != nil {
+			continue
+		}
+		history = append(history, HistoryEntry{
+			ID:   id,
+			Path: filepath.Join(c.HistoryDir(), name),
+			Time: stamp,
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool { return history[i].ID > history[j].ID })
+	return history, nil
+}
+
+// LookupHistory looks up a snapshot by ID. An empty ID selects the newest one.
+func (c *ConfigFile[T]) LookupHistory(id string) (HistoryEntry, error) {
+	history, err := c.History()
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	for _, entry := range history {
+		if id == "" || entry.ID == id {
+			return entry, nil
+		}
+	}
+	if id == "" {
+		return HistoryEntry{}, fmt.Errorf("%w: no snapshots available", ErrHistoryNotFound)
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: %s", ErrHistoryNotFound, id)
+}
+
+// DiffHistory reports how the configuration file differs from the snapshot
+// with the given ID (the newest one when id is empty). Both files are
+// compared as written, without the files layered over them and with
+// encrypted secrets and placeholders left as they are.
+func (c *ConfigFile[T]) DiffHistory(id string) ([]Change, error) {
+	entry, err := c.LookupHistory(id)
+	if err != nil {
+		return nil, err
+	}
+	from, err := c.rawDocument(entry.Path)
+	if err != nil {
+		return nil, fmt.Errorf("history entry %s: %w", entry.ID, err)
+	}
+	to, err := c.rawDocument(c.Path())
+	if err != nil {
+		return nil, err
+	}
+	return Diff(from, to), nil
+}
+
+// rawDocument decodes the file at path into a generic document without
+// interpolating or layering it. A missing or empty file is an empty
+// document.
+func (c *ConfigFile[T]) rawDocument(path string) (map[string]any, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(bytes.TrimSpace(buf)) == 0) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		withPath(err, path)
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Rollback restores the snapshot with the given ID (the newest one when id is
+// empty). With WithTrustedKeys the snapshot must carry a valid signature, which
+// is restored with it unless a signing key re-signs the file. The snapshot is
+// decoded over the same layers as the configuration file and validated
+// according to the validation policy before it replaces the current file, which
+// is itself saved to the history first unless the history retention is zero.
+// The restored file is then reloaded.
+func (c *ConfigFile[T]) Rollback(id string) error {
+	if err := c.checkWritable("roll back configuration"); err != nil {
+		return err
+	}
+	entry, err := c.LookupHistory(id)
+	if err != nil {
+		return err
+	}
+
+	buf, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return fmt.Errorf("read history entry: %w", err)
+	}
+	if err := c.verifyLayer(entry.Path, buf); err != nil {
+		return fmt.Errorf("history entry %s: %w", entry.ID, err)
+	}
+	data, _, err := c.readData(entry.Path, true)
+	if err != nil {
+		return fmt.Errorf("decode history entry %s: %w", entry.ID, err)
+	}
+	if err := c.validate(data, entry.Path); err != nil {
+		return fmt.Errorf("history entry %s is invalid: %w", entry.ID, err)
+	}
+	if err := afterLoad(&data); err != nil {
+		return err
+	}
+
+	if c.historyRetention > 0 {
+		if _, err := c.Backup(); err != nil {
+			return err
+		}
+	}
+	if err := fs.WriteFileWithDirs(c.Path(), buf, fs.RestrictedFileMode); err != nil {
+		return fmt.Errorf("restore history entry: %w", err)
+	}
+	if err := c.restoreSignature(entry.Path, buf); err != nil {
+		return err
+	}
+	return c.Reload()
+}
+
+// Backup stores a snapshot of the current configuration file, together with
+// its signature file if any, in the history directory and returns its path.
+// It returns an empty path when there is no file to back up. Identical
+// consecutive snapshots are not duplicated.
+func (c *ConfigFile[T]) Backup() (string, error) {
+	if err := c.checkWritable("back up configuration"); err != nil {
+		return "", err
+	}
+	exists, err := c.Exists()
+	if err != nil || !exists {
+		return "", err
+	}
+
+	buf, err := c.Content()
+	if err != nil {
+		return "", err
+	}
+
+	history, err := c.History()
+	if err != nil {
+		return "", err
+	}
+	if len(history) > 0 {
+		if latest, err := os.ReadFile(history[0].Path); err == nil && bytes.Equal(latest, buf) {
+			return history[0].Path, nil
+		}
+	}
+
+	id := time.Now().Format(historyIDLayout)
+	backupPath := filepath.Join(c.HistoryDir(), fmt.Sprintf("%s.%s", filepath.Base(c.Path()), id))
+	if err := fs.WriteFileWithDirs(backupPath, buf, fs.RestrictedFileMode); err != nil {
+		return "", fmt.Errorf("write configuration backup: %w", err)
+	}
+	if err := copySignature(c.Path(), backupPath); err != nil {
+		return "", fmt.Errorf("write configuration backup: %w", err)
+	}
+
+	if err := c.pruneHistory(); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// pruneHistory removes the oldest snapshots beyond the configured retention.
+func (c *ConfigFile[T]) pruneHistory() error {
+	if c.historyRetention <= 0 {
+		return nil
+	}
+	history, err := c.History()
+	if err != nil {
+		return err
+	}
+	for _, entry := range history[min(c.historyRetention, len(history)):] {
+		for _, path := range []string{entry.Path, entry.Path + signatureSuffix} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("prune history: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The configuration type may implement any of the following interfaces, on
+// the value or on the pointer, to take part in loading and saving. When the
+// configuration file is loaded by Reload, SoftInit, or Load, the steps run in
+// this order:
+//
+//  1. the file is decoded over its layers, secrets are decrypted, secret
+//     references resolved, and overrides applied;
+//  2. SetDefaults fills in the settings that are still empty;
+//  3. Normalize rewrites values into their canonical form;
+//  4. Validate checks the result according to the validation policy;
+//  5. AfterLoad computes derived state.
+//
+// When data is written by Init, Set, Reset, and the other writing
+// operations, it is validated first, and then BeforeSave runs on a copy that
+// is encrypted and written; the in-memory data is left as it was.
+//
+// Values changed by SetDefaults and Normalize are not written back to the
+// file unless they are changed afterwards, so the file keeps, for example,
+// an unexpanded ~ in a path.
+type (
+	// Defaulter is implemented by configuration types that fill in
+	// settings the loaded layers left empty.
+	Defaulter interface {
+		SetDefaults()
+	}
+	// Normalizer is implemented by configuration types that rewrite loaded
+	// values into a canonical form, such as expanding ~ in paths.
+	Normalizer interface {
+		Normalize() error
+	}
+	// AfterLoader is implemented by configuration types that compute
+	// derived state once the loaded data has been validated.
+	AfterLoader interface {
+		AfterLoad() error
+	}
+	// BeforeSaver is implemented by configuration types that clean up the
+	// data before it is written to the file.
+	BeforeSaver interface {
+		BeforeSave() error
+	}
+)
+
+// prepareData runs SetDefaults and Normalize on data and records the values
+// they change in preserved, with their form in doc, the main file's document.
+func (c *ConfigFile[T]) prepareData(data *T, doc map[string]any, preserved map[string]preservedValue) error {
+	defaulter, hasDefaults := any(data).(Defaulter)
+	normalizer, hasNormalize := any(data).(Normalizer)
+	if !hasDefaults && !hasNormalize {
+		return nil
+	}
+
+	before, err := c.document(*data)
+	if err != nil {
+		return err
+	}
+	if hasDefaults {
+		defaulter.SetDefaults()
+	}
+	if hasNormalize {
+		if err := normalizer.Normalize(); err != nil {
+			return fmt.Errorf("normalize configuration: %w", err)
+		}
+	}
+	after, err := c.document(*data)
+	if err != nil {
+		return err
+	}
+
+	previous := flattenDocument(before)
+	for key, value := range flattenDocument(after) {
+		if old, ok := previous[key]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		if existing, ok := preserved[key]; ok {
+			existing.loaded = value
+			preserved[key] = existing
+			continue
+		}
+		raw, inFile := lookupKey(doc, key)
+		preserved[key] = preservedValue{raw: raw, loaded: value, inFile: inFile}
+	}
+	return nil
+}
+
+// afterLoad runs the AfterLoad hook of data, if any.
+func afterLoad[T Validatable](data *T) error {
+	if loader, ok := any(data).(AfterLoader); ok {
+		if err := loader.AfterLoad(); err != nil {
+			return fmt.Errorf("after load: %w", err)
+		}
+	}
+	return nil
+}
+
+// beforeSave returns the copy of data to write, after running its
+// BeforeSave hook, if any.
+func (c *ConfigFile[T]) beforeSave(data T) (T, error) {
+	if _, ok := any(&data).(BeforeSaver); !ok {
+		return data, nil
+	}
+	saved, err := c.clone(data)
+	if err != nil {
+		return data, err
+	}
+	if err := any(&saved).(BeforeSaver).BeforeSave(); err != nil {
+		return data, fmt.Errorf("before save: %w", err)
+	}
+	return saved, nil
+}
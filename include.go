@@ -0,0 +1,216 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// includeKey is the document key that lists additional files to merge.
+const includeKey = "include"
+
+// DropInDir returns the directory whose files are merged over the
+// configuration file in lexical order (config.d for config.yml).
+func (c *ConfigFile[T]) DropInDir() string {
+	base := strings.TrimSuffix(c.fileName, filepath.Ext(c.fileName))
+	return filepath.Join(c.DirPath(), base+".d")
+}
+
+// overlayFiles lists the files merged over the main configuration file: the
+// drop-in files followed by the discovered project files.
+func (c *ConfigFile[T]) overlayFiles() ([]string, error) {
+	dropIns, err := c.dropIns()
+	if err != nil {
+		return nil, err
+	}
+	discovered, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	c.discovered = discovered
+	return append(dropIns, discovered...), nil
+}
+
+// dropIns lists the drop-in files that use the managed file's extension,
+// sorted lexically.
+func (c *ConfigFile[T]) dropIns() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(c.DropInDir(), "*"+c.fileManager.Extension()))
+	if err != nil {
+		return nil, fmt.Errorf("list drop-in files: %w", err)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// layerDocument merges the files listed under the include key of doc and its
+// matching conditional sections, and then the overlay files in order, over doc,
+// and places the result over base when it is not nil. It returns nil when there
+// is nothing to merge. Keys contributed by other files are recorded in
+// preserved so they are not copied into the main file when it is written.
+func (c *ConfigFile[T]) layerDocument(path string, base, doc map[string]any, overlays []string, preserved map[string]preservedValue) (map[string]any, error) {
+	_, hasInclude := doc[includeKey]
+	_, hasMatch := doc[matchKey]
+	if !hasInclude && !hasMatch && base == nil && len(overlays) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range []string{includeKey, matchKey} {
+		if raw, ok := doc[key]; ok {
+			preserved[key] = preservedValue{raw: raw, inFile: true, keep: true}
+		}
+	}
+
+	stack := []string{absPath(path)}
+	merged, err := c.mergeIncludes(path, doc, stack)
+	if err != nil {
+		return nil, err
+	}
+	for _, overlay := range overlays {
+		load := c.loadInclude
+		if slices.Contains(c.discovered, overlay) {
+			load = c.loadProjectFile
+		}
+		layer, err := load(overlay, stack)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeDocuments(merged, layer)
+	}
+	if base != nil {
+		merged = mergeDocuments(base, merged)
+	}
+
+	recordPreserved(preserved, withoutDirectives(doc), merged)
+	return merged, nil
+}
+
+// mergeIncludes merges the files matched by the include patterns of doc, in
+// order, over doc, and then the conditional sections of doc that match this
+// machine. Patterns are globs relative to DirPath. path names the file doc
+// was read from and stack holds the chain of including files.
+func (c *ConfigFile[T]) mergeIncludes(path string, doc map[string]any, stack []string) (map[string]any, error) {
+	patterns, err := includePatterns(doc[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	merged := withoutDirectives(doc)
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(c.DirPath(), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %q: %w", path, pattern, err)
+		}
+		if len(matches) == 0 && !hasGlobMeta(pattern) {
+			return nil, fmt.Errorf("%s: include %q: %w", path, pattern, os.ErrNotExist)
+		}
+		slices.Sort(matches)
+
+		for _, match := range matches {
+			layer, err := c.loadInclude(match, stack)
+			if err != nil {
+				return nil, fmt.Errorf("%s: include %q: %w", path, match, err)
+			}
+			merged = mergeDocuments(merged, layer)
+		}
+	}
+
+	if merged, err = applyMatches(doc, merged); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return merged, nil
+}
+
+// withoutDirectives returns a copy of doc without the include and match
+// keys, which are not settings.
+func withoutDirectives(doc map[string]any) map[string]any {
+	settings := make(map[string]any, len(doc))
+	for key, value := range doc {
+		if key != includeKey && key != matchKey {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+// loadInclude reads an included file and resolves its own includes.
+func (c *ConfigFile[T]) loadInclude(path string, stack []string) (map[string]any, error) {
+	abs := absPath(path)
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+	}
+	doc, err := c.readLayer(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.mergeIncludes(path, doc, append(slices.Clone(stack), abs))
+}
+
+// readLayer reads, verifies, and decodes a file merged into the configuration,
+// with its renamed keys moved to their current names. Its includes are not
+// resolved.
+func (c *ConfigFile[T]) readLayer(path string) (map[string]any, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifyLayer(path, buf); err != nil {
+		return nil, err
+	}
+	if c.interpolate {
+		if buf, err = interpolateEnv(buf); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if withPath(err, path) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, deprecation := range c.migrateKeys(path, doc, nil) {
+		c.warn(deprecation)
+	}
+	return doc, nil
+}
+
+// includePatterns normalizes the include value, which may be a single
+// pattern or a list of patterns.
+func includePatterns(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, errors.New("include entries must be strings")
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	default:
+		return nil, errors.New("include must be a string or a list of strings")
+	}
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// interpolationPattern matches the body of ${VAR}, ${VAR:-default} and
+// ${VAR:?message} placeholders.
+var interpolationPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)(.*))?$`)
+
+// WithInterpolation enables environment variable interpolation. Before the
+// file is decoded every ${VAR} is replaced with the value of VAR,
+// ${VAR:-default} falls back to default when VAR is unset or empty,
+// ${VAR:?message} fails with message in that case, and $$ produces a literal
+// $. Values are inserted verbatim into the document, and unchanged values
+// are written back with their placeholders instead of the expanded text.
+// Other written values are escaped so they read back as they were set.
+func WithInterpolation[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.interpolate = true
+	}
+}
+
+// interpolateEnv expands the placeholders found in buf using os.LookupEnv.
+// Anything that is not a valid placeholder, such as a ${secret:...}
+// reference, is left untouched.
+func interpolateEnv(buf []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(buf))
+
+	for i := 0; i < len(buf); i++ {
+		if buf[i] != '$' || i+1 >= len(buf) {
+			out.WriteByte(buf[i])
+			continue
+		}
+
+		switch buf[i+1] {
+		case '$':
+			out.WriteByte('$')
+			i++
+		case '{':
+			end := bytes.IndexByte(buf[i+2:], '}')
+			if end < 0 {
+				out.WriteByte(buf[i])
+				continue
+			}
+			body := string(buf[i+2 : i+2+end])
+			value, ok, err := expandPlaceholder(body)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				out.WriteByte(buf[i])
+				continue
+			}
+			out.WriteString(value)
+			i += 2 + end
+		default:
+			out.WriteByte(buf[i])
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// expandPlaceholder evaluates the body of a ${...} placeholder. It reports
+// false when body is not an interpolation placeholder.
+func expandPlaceholder(body string) (string, bool, error) {
+	groups := interpolationPattern.FindStringSubmatch(body)
+	if groups == nil {
+		return "", false, nil
+	}
+
+	name, operator, argument := groups[1], groups[2], groups[3]
+	value, set := os.LookupEnv(name)
+	if set && value != "" {
+		return value, true, nil
+	}
+
+	switch operator {
+	case ":-":
+		return argument, true, nil
+	case ":?":
+		if argument == "" {
+			argument = "is not set"
+		}
+		return "", false, fmt.Errorf("interpolate ${%s}: %s %s", name, name, argument)
+	default:
+		return value, true, nil
+	}
+}
+
+// interpolateDocument expands the placeholders in buf and records in
+// preserved the keys whose on-disk value contains placeholders. Documents
+// that only parse after expansion (e.g. unquoted JSON placeholders) are
+// expanded but cannot be preserved.
+func (c *ConfigFile[T]) interpolateDocument(buf []byte, preserved map[string]preservedValue) ([]byte, error) {
+	expanded, err := interpolateEnv(buf)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(expanded, buf) {
+		return expanded, nil
+	}
+
+	rawDoc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		return expanded, nil
+	}
+	expandedDoc, err := c.fileManager.UnmarshalDocument(expanded)
+	if err != nil {
+		// Let the typed decoder report the syntax error.
+		return expanded, nil
+	}
+	recordPreserved(preserved, rawDoc, expandedDoc)
+	return expanded, nil
+}
+
+// escapeDocument escapes the string values of the encoded document buf that
+// interpolation would otherwise change when the file is read back.
+func (c *ConfigFile[T]) escapeDocument(buf []byte) ([]byte, error) {
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]any)
+	for key, value := range flattenDocument(doc) {
+		if escaped, ok := escapeValue(value); ok {
+			set[key] = escaped
+		}
+	}
+	if len(set) == 0 {
+		return buf, nil
+	}
+	return c.fileManager.PatchDocument(buf, set, nil)
+}
+
+// escapeValue doubles every $ of the strings in value, including those in
+// lists, when one of them holds a $$ or ${ sequence. It reports whether
+// anything had to be escaped.
+func escapeValue(value any) (any, bool) {
+	switch value := value.(type) {
+	case string:
+		if !strings.Contains(value, "$$") && !strings.Contains(value, "${") {
+			return value, false
+		}
+		return strings.ReplaceAll(value, "$", "$$"), true
+	case []any:
+		escaped := make([]any, len(value))
+		changed := false
+		for i, item := range value {
+			var ok bool
+			escaped[i], ok = escapeValue(item)
+			changed = changed || ok
+		}
+		return escaped, changed
+	case map[string]any:
+		escaped := make(map[string]any, len(value))
+		changed := false
+		for key, item := range value {
+			var ok bool
+			escaped[key], ok = escapeValue(item)
+			changed = changed || ok
+		}
+		return escaped, changed
+	default:
+		return value, false
+	}
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/vekio/x/fs"
 )
@@ -23,23 +26,25 @@ func (b *JSONFileManager[T]) Extension() string {
 }
 
 // LoadDataFromFile reads the JSON file, unmarshals it into the provided value,
-// and returns an error if the file cannot be read or parsed.
+// and returns an error if the file cannot be read or a *ParseError if it
+// cannot be parsed.
 func (b *JSONFileManager[T]) LoadDataFromFile(filePath string, data *T) error {
 	buf, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("read JSON file: %w", err)
 	}
-	if err := json.Unmarshal(buf, data); err != nil {
-		return fmt.Errorf("error unmarshaling JSON data: %w", err)
+	if err := b.Unmarshal(buf, data); err != nil {
+		withPath(err, filePath)
+		return err
 	}
 	return nil
 }
 
 // WriteDataToFile serializes the value as JSON and persists it to disk.
 func (b *JSONFileManager[T]) WriteDataToFile(filePath string, data T) error {
-	buf, err := json.MarshalIndent(data, "", "  ")
+	buf, err := b.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("error marshaling JSON data: %w", err)
+		return err
 	}
 	// Ensure the destination exists and persists the encoded payload.
 	if err := fs.WriteFileWithDirs(filePath, buf, fs.RestrictedFileMode); err != nil {
@@ -47,3 +52,181 @@ func (b *JSONFileManager[T]) WriteDataToFile(filePath string, data T) error {
 	}
 	return nil
 }
+
+// Marshal encodes the value as indented JSON.
+func (b *JSONFileManager[T]) Marshal(data T) ([]byte, error) {
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JSON data: %w", err)
+	}
+	return buf, nil
+}
+
+// Unmarshal decodes a JSON payload into the provided value. Decoding errors
+// are returned as a *ParseError.
+func (b *JSONFileManager[T]) Unmarshal(buf []byte, data *T) error {
+	if err := json.Unmarshal(buf, data); err != nil {
+		return newJSONParseError(buf, err)
+	}
+	return nil
+}
+
+// UnmarshalDocument decodes a JSON object into a generic document. Numbers
+// are kept as json.Number so integers survive a round trip unchanged.
+func (b *JSONFileManager[T]) UnmarshalDocument(buf []byte) (map[string]any, error) {
+	doc := map[string]any{}
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return doc, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, newJSONParseError(buf, err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	return doc, nil
+}
+
+// MarshalDocument encodes a generic document as indented JSON.
+func (b *JSONFileManager[T]) MarshalDocument(doc map[string]any) ([]byte, error) {
+	buf, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JSON document: %w", err)
+	}
+	return buf, nil
+}
+
+// PatchDocument rewrites the JSON object in buf, replacing the value stored
+// under each dotted key in set and deleting the dotted keys in remove. Keys
+// keep their original order and new keys are appended.
+func (b *JSONFileManager[T]) PatchDocument(buf []byte, set map[string]any, remove []string) ([]byte, error) {
+	root, err := parseOrderedJSON(buf)
+	if err != nil {
+		return nil, newJSONParseError(buf, err)
+	}
+
+	for key, value := range set {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling JSON value for %s: %w", key, err)
+		}
+		root.set(strings.Split(key, "."), encoded)
+	}
+	for _, key := range remove {
+		root.remove(strings.Split(key, "."))
+	}
+
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JSON document: %w", err)
+	}
+	return out, nil
+}
+
+// orderedJSON is a JSON object that remembers the order of its keys. Values
+// are either nested *orderedJSON objects or raw JSON.
+type orderedJSON struct {
+	keys   []string
+	values map[string]any
+}
+
+func parseOrderedJSON(buf []byte) (*orderedJSON, error) {
+	obj := &orderedJSON{values: map[string]any{}}
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return obj, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var value any = raw
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+			if value, err = parseOrderedJSON(raw); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, exists := obj.values[key]; !exists {
+			obj.keys = append(obj.keys, key)
+		}
+		obj.values[key] = value
+	}
+	return obj, nil
+}
+
+func (o *orderedJSON) set(path []string, value json.RawMessage) {
+	key := path[0]
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	if len(path) == 1 {
+		o.values[key] = value
+		return
+	}
+
+	nested, ok := o.values[key].(*orderedJSON)
+	if !ok {
+		nested = &orderedJSON{values: map[string]any{}}
+		o.values[key] = nested
+	}
+	nested.set(path[1:], value)
+}
+
+func (o *orderedJSON) remove(path []string) {
+	key := path[0]
+	if len(path) > 1 {
+		if nested, ok := o.values[key].(*orderedJSON); ok {
+			nested.remove(path[1:])
+		}
+		return
+	}
+	if _, exists := o.values[key]; !exists {
+		return
+	}
+	delete(o.values, key)
+	o.keys = slices.DeleteFunc(o.keys, func(k string) bool { return k == key })
+}
+
+// MarshalJSON encodes the object compactly, preserving key order.
+func (o *orderedJSON) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
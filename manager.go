@@ -12,3 +12,25 @@ type FileManager[T Validatable] interface {
 	// dot) used for files handled by this manager.
 	Extension() string
 }
+
+// documentManager is implemented by the file managers that can also work on
+// encoded and generic documents, which layering, diffs, and in-place edits
+// need. It is checked with a type assertion when the configuration file is
+// built, which fails for file managers that do not provide these methods.
+type documentManager[T Validatable] interface {
+	FileManager[T]
+	// Marshal encodes the given value using the manager's format.
+	Marshal(data T) ([]byte, error)
+	// Unmarshal decodes buf into the provided struct pointer.
+	Unmarshal(buf []byte, data *T) error
+	// UnmarshalDocument decodes buf into a generic key/value document so it
+	// can be inspected without knowing the concrete configuration type.
+	UnmarshalDocument(buf []byte) (map[string]any, error)
+	// MarshalDocument encodes a generic key/value document using the
+	// manager's format.
+	MarshalDocument(doc map[string]any) ([]byte, error)
+	// PatchDocument rewrites an encoded document, replacing the values of
+	// the dotted keys in set and deleting the dotted keys in remove while
+	// keeping the order of every other key.
+	PatchDocument(buf []byte, set map[string]any, remove []string) ([]byte, error)
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// matchKey is the document key that lists conditional sections, each merged
+// over the rest of the file when its conditions hold on this machine:
+//
+//	match:
+//	  - when: {hostname: "build-*", os: linux}
+//	    set:
+//	      workers: 16
+const matchKey = "match"
+
+// hostname returns the machine's host name; tests replace it.
+var hostname = os.Hostname
+
+// matchConditions lists the conditions a conditional section may use and
+// how to read them on this machine.
+var matchConditions = map[string]func() (string, error){
+	"hostname": func() (string, error) { return hostname() },
+	"os":       func() (string, error) { return runtime.GOOS, nil },
+	"arch":     func() (string, error) { return runtime.GOARCH, nil },
+}
+
+// applyMatches merges the set values of every section listed under the match
+// key of doc whose conditions hold, in order, over merged.
+func applyMatches(doc, merged map[string]any) (map[string]any, error) {
+	raw, ok := doc[matchKey]
+	if !ok || raw == nil {
+		return merged, nil
+	}
+	sections, ok := raw.([]any)
+	if !ok {
+		return nil, errors.New("match must be a list of sections")
+	}
+
+	for i, item := range sections {
+		section, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("match[%d]: section must be a mapping", i)
+		}
+		for key := range section {
+			if key != "when" && key != "set" {
+				return nil, fmt.Errorf("match[%d]: unknown key %q", i, key)
+			}
+		}
+		matched, err := sectionMatches(section["when"])
+		if err != nil {
+			return nil, fmt.Errorf("match[%d]: %w", i, err)
+		}
+		if !matched {
+			continue
+		}
+		values, ok := section["set"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("match[%d]: set must be a mapping", i)
+		}
+		merged = mergeDocuments(merged, values)
+	}
+	return merged, nil
+}
+
+// sectionMatches reports whether every condition of when holds. Each
+// condition is a glob pattern, or a list of patterns of which any may match.
+func sectionMatches(when any) (bool, error) {
+	conditions, ok := when.(map[string]any)
+	if !ok {
+		return false, errors.New("when must be a mapping of conditions")
+	}
+
+	names := make([]string, 0, len(conditions))
+	for name := range conditions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		read, ok := matchConditions[name]
+		if !ok {
+			return false, fmt.Errorf("unknown condition %q", name)
+		}
+		patterns, err := conditionPatterns(conditions[name])
+		if err != nil {
+			return false, fmt.Errorf("condition %q: %w", name, err)
+		}
+		value, err := read()
+		if err != nil {
+			return false, fmt.Errorf("condition %q: %w", name, err)
+		}
+		matched, err := matchesAny(patterns, strings.ToLower(value))
+		if err != nil {
+			return false, fmt.Errorf("condition %q: %w", name, err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// conditionPatterns normalizes a condition value, which may be a single
+// pattern or a list of patterns.
+func conditionPatterns(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, errors.New("patterns must be strings")
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	default:
+		return nil, errors.New("must be a string or a list of strings")
+	}
+}
+
+// matchesAny reports whether value matches any of the case-insensitive glob
+// patterns.
+func matchesAny(patterns []string, value string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.ToLower(pattern), value)
+		if err != nil {
+			return false, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
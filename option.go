@@ -37,8 +37,8 @@ func WithAppName[T Validatable](appName string) ConfigFileOption[T] {
 }
 
 // WithPath overrides the directory where configuration files are stored. When
-// the provided path is empty the default user configuration directory (or the
-// system temp directory as fallback) is used.
+// the provided path is empty the default user configuration directory
+// ($XDG_CONFIG_HOME or the platform default) is used.
 func WithPath[T Validatable](path string) ConfigFileOption[T] {
 	return func(c *ConfigFile[T]) {
 		if c == nil {
@@ -53,6 +53,18 @@ func WithPath[T Validatable](path string) ConfigFileOption[T] {
 	}
 }
 
+// WithFile points the ConfigFile at an explicit file instead of the path
+// derived from the configuration directory, application name, and profile.
+// The format is detected from the extension (.json, .yml, or .yaml).
+func WithFile[T Validatable](path string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.file = strings.TrimSpace(path)
+	}
+}
+
 // WithFilename overrides the default file name while ensuring it uses the
 // extension that matches the underlying file manager.
 func WithFilename[T Validatable](fileName string) ConfigFileOption[T] {
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// usageTag is the struct tag that documents a field, e.g. for generated
+// command-line flags.
+const usageTag = "doc"
+
+// Field describes a leaf setting of the configuration type.
+type Field struct {
+	// Key is the dotted document key of the field.
+	Key string
+	// Type is the Go type of the field.
+	Type reflect.Type
+	// Usage is the text of the field's doc tag.
+	Usage string
+	// Default is the field's value in the default data.
+	Default any
+	// Sensitive reports whether the field holds a secret.
+	Sensitive bool
+	// Aliases lists the former keys of the field, from its alias tag.
+	Aliases []string
+	// Deprecated is the text of the field's deprecated tag.
+	Deprecated string
+
+	index []int
+}
+
+// Fields lists the leaf settings of T, following nested structs, in
+// declaration order. Keys use the same names as the configuration file.
+func (c *ConfigFile[T]) Fields() []Field {
+	defaults := reflect.ValueOf(c.defaultData)
+	fields := make([]Field, 0)
+	var walk func(t reflect.Type, key string, index []int, sensitive bool)
+	walk = func(t reflect.Type, key string, index []int, sensitive bool) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, inline, skip := fieldKey(field, c.structTag())
+			if skip {
+				continue
+			}
+			fieldKey := key
+			if !inline {
+				fieldKey = joinKey(key, name)
+			}
+			fieldIndex := append(slices.Clone(index), i)
+			fieldSensitive := sensitive || isSensitiveField(field) || field.Type == secretType
+
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, fieldKey, fieldIndex, fieldSensitive)
+				continue
+			}
+			fields = append(fields, Field{
+				Key:        fieldKey,
+				Type:       field.Type,
+				Usage:      field.Tag.Get(usageTag),
+				Default:    defaults.FieldByIndex(fieldIndex).Interface(),
+				Sensitive:  fieldSensitive,
+				Aliases:    fieldAliases(field.Tag.Get(aliasTag)),
+				Deprecated: field.Tag.Get(deprecatedTag),
+				index:      fieldIndex,
+			})
+		}
+	}
+	if t := defaults.Type(); t.Kind() == reflect.Struct {
+		walk(t, "", nil, false)
+	}
+	return fields
+}
+
+// SetOverrides replaces the values applied over every other layer when the
+// configuration is loaded, keyed by the dotted keys reported by Fields.
+// They take effect on the next load and are not written to the file unless
+// they are changed afterwards.
+func (c *ConfigFile[T]) SetOverrides(values map[string]any) error {
+	fields := make(map[string]Field)
+	for _, field := range c.Fields() {
+		fields[field.Key] = field
+	}
+	for key, value := range values {
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("override %q: unknown key", key)
+		}
+		if !assignable(reflect.TypeOf(value), field.Type) {
+			return fmt.Errorf("override %q: cannot use %T as %s", key, value, field.Type)
+		}
+	}
+	c.overrides = maps.Clone(values)
+	return nil
+}
+
+// Overrides returns the values set with SetOverrides.
+func (c *ConfigFile[T]) Overrides() map[string]any {
+	return maps.Clone(c.overrides)
+}
+
+// applyOverrides sets the override values on data and records them in
+// preserved so the file keeps its own values. doc is the main file's
+// document. Overrides of keys locked by policy are skipped and reported to
+// the warning handler.
+func (c *ConfigFile[T]) applyOverrides(data *T, doc, policy map[string]any, preserved map[string]preservedValue) error {
+	if len(c.overrides) == 0 {
+		return nil
+	}
+
+	v := reflect.ValueOf(data).Elem()
+	applied := make([]string, 0, len(c.overrides))
+	for _, field := range c.Fields() {
+		value, ok := c.overrides[field.Key]
+		if !ok {
+			continue
+		}
+		if lockedBy(policy, field.Key) {
+			c.warn(fmt.Errorf("override %q ignored: %w", field.Key, ErrLocked))
+			continue
+		}
+		target := v.FieldByIndex(field.index)
+		target.Set(reflect.ValueOf(value).Convert(target.Type()))
+		applied = append(applied, field.Key)
+	}
+
+	loaded, err := c.document(*data)
+	if err != nil {
+		return err
+	}
+	for _, key := range applied {
+		value, _ := lookupKey(loaded, key)
+		if existing, ok := preserved[key]; ok {
+			existing.loaded = value
+			preserved[key] = existing
+			continue
+		}
+		raw, inFile := lookupKey(doc, key)
+		preserved[key] = preservedValue{raw: raw, loaded: value, inFile: inFile}
+	}
+	return nil
+}
+
+// assignable reports whether a value of type from can be stored in a field
+// of type to without changing its meaning.
+func assignable(from, to reflect.Type) bool {
+	if from == nil {
+		return false
+	}
+	if from.AssignableTo(to) {
+		return true
+	}
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	return kindClass(from.Kind()) == kindClass(to.Kind())
+}
+
+func kindClass(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice:
+		return "slice"
+	default:
+		return strings.ToLower(kind.String())
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// ParseError reports that a configuration file could not be decoded. Line
+// and Column are 1-based and zero when unknown.
+type ParseError struct {
+	// Path is the file that failed to parse, or empty when the data did not
+	// come from a file.
+	Path string
+	// Line is the line of the offending input.
+	Line int
+	// Column is the column of the offending input.
+	Column int
+	// Format names the encoding, such as "JSON" or "YAML".
+	Format string
+	// Err is the error returned by the decoder.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		if location != "" {
+			location += ":"
+		}
+		location += strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	if location == "" {
+		return fmt.Sprintf("invalid %s: %v", e.Format, e.Err)
+	}
+	return fmt.Sprintf("%s: invalid %s: %v", location, e.Format, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// yamlLinePattern extracts the position from yaml.v3 error messages.
+var yamlLinePattern = regexp.MustCompile(`line (\d+)(?:, column (\d+))?`)
+
+// newJSONParseError wraps a decoding error of buf, converting the byte
+// offset reported by encoding/json into a line and column.
+func newJSONParseError(buf []byte, err error) *ParseError {
+	perr := &ParseError{Format: "JSON", Err: err}
+
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset points just past the offending byte.
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset - 1
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		offset = int64(len(buf))
+	}
+	if offset >= 0 {
+		perr.Line, perr.Column = lineColumn(buf, int(min(offset, int64(len(buf)))))
+	}
+	return perr
+}
+
+// newYAMLParseError wraps a decoding error from yaml.v3, which reports the
+// line, and sometimes the column, in its message.
+func newYAMLParseError(err error) *ParseError {
+	perr := &ParseError{Format: "YAML", Err: err}
+	if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		perr.Line, _ = strconv.Atoi(match[1])
+		perr.Column, _ = strconv.Atoi(match[2])
+	}
+	return perr
+}
+
+// lineColumn returns the 1-based line and column of the byte at offset.
+func lineColumn(buf []byte, offset int) (int, int) {
+	before := buf[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// withPath records path on the ParseError wrapped by err, if any, and
+// reports whether there was one.
+func withPath(err error, path string) bool {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return false
+	}
+	if perr.Path == "" {
+		perr.Path = path
+	}
+	return true
+}
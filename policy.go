@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// policyFileName is the base name of the policy file, without extension.
+const policyFileName = "policy"
+
+// ErrLocked is returned when a key locked by the policy file is changed.
+var ErrLocked = errors.New("config: key is locked by policy")
+
+// WithPolicyFile sets the policy file instead of the default
+// /etc/<app>/policy.<ext>. It uses the same format as the configuration
+// file.
+func WithPolicyFile[T Validatable](path string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(path)
+		if trimmed == "" {
+			return
+		}
+		c.policyFile = filepath.Clean(trimmed)
+	}
+}
+
+// PolicyPath returns the policy file administrators use to pin settings:
+// /etc/<app>/policy.<ext>, or %ProgramData%\<app>\policy.<ext> on Windows.
+// Every key it sets is locked: it wins over the user's files and overrides
+// and cannot be changed with Set.
+func (c *ConfigFile[T]) PolicyPath() string {
+	if c.policyFile != "" {
+		return c.policyFile
+	}
+	dir := "/etc"
+	if runtime.GOOS == "windows" {
+		dir = os.Getenv("ProgramData")
+	}
+	return filepath.Join(dir, c.appName, policyFileName+c.fileManager.Extension())
+}
+
+// LockedKeys lists the dotted keys set by the policy file, sorted. It
+// returns nil when there is no policy file.
+func (c *ConfigFile[T]) LockedKeys() ([]string, error) {
+	policy, err := c.policyDocument()
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for key := range flattenDocument(policy) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// IsLocked reports whether the policy file locks key, either directly or
+// through a parent or child key.
+func (c *ConfigFile[T]) IsLocked(key string) (bool, error) {
+	policy, err := c.policyDocument()
+	if err != nil {
+		return false, err
+	}
+	return lockedBy(policy, key), nil
+}
+
+// policyDocument reads the policy file. It returns nil when there is none.
+func (c *ConfigFile[T]) policyDocument() (map[string]any, error) {
+	path := c.PolicyPath()
+	if path == c.Path() {
+		return nil, nil
+	}
+	policy, err := c.loadInclude(path, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load policy file: %w", err)
+	}
+	return policy, nil
+}
+
+// applyPolicy places policy over the loaded document and records the locked
+// keys in preserved so the user's own values stay in the file. It returns
+// layered unchanged when there is no policy. doc is the main file's document
+// and layered the result of merging the other layers, or nil.
+func applyPolicy(policy, doc, layered map[string]any, preserved map[string]preservedValue) map[string]any {
+	if policy == nil {
+		return layered
+	}
+	if layered == nil {
+		layered = doc
+	}
+	locked := mergeDocuments(layered, policy)
+	for key, value := range flattenDocument(policy) {
+		if existing, ok := preserved[key]; ok {
+			existing.loaded = value
+			preserved[key] = existing
+			continue
+		}
+		raw, inFile := lookupKey(doc, key)
+		if inFile && reflect.DeepEqual(raw, value) {
+			continue
+		}
+		preserved[key] = preservedValue{raw: raw, loaded: value, inFile: inFile}
+	}
+	return locked
+}
+
+// lockedBy reports whether key overlaps a key set by policy.
+func lockedBy(policy map[string]any, key string) bool {
+	for lockedKey := range flattenDocument(policy) {
+		if keysOverlap(key, lockedKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// keysOverlap reports whether two dotted keys are equal or one is a parent
+// of the other.
+func keysOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/vekio/x/fs"
+)
+
+const (
+	// DefaultProfile names the base configuration file.
+	DefaultProfile = "default"
+	// profileMarkerName is the file under DirPath that stores the profile
+	// selected with UseProfile.
+	profileMarkerName = ".current-profile"
+	// extendsKey names the profile a profile file inherits from.
+	extendsKey = "extends"
+)
+
+var (
+	// ErrProfileNotFound is returned when a requested profile has no file.
+	ErrProfileNotFound = errors.New("config: profile not found")
+	// ErrProfileExists is returned when creating a profile that already exists.
+	ErrProfileExists = errors.New("config: profile already exists")
+)
+
+// profileNamePattern restricts profile names to what can be embedded in a
+// file name (config.<profile>.yml).
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// WithProfile selects the named profile, stored next to the base file as
+// config.<profile>.<ext> and deep-merged over it. A profile can inherit from
+// another profile instead by naming it under the extends key. It takes
+// precedence over the <APP>_PROFILE variable and the profile persisted with
+// UseProfile.
+func WithProfile[T Validatable](name string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.profile = normalizeProfile(name)
+	}
+}
+
+// WithProfileReplace makes profile files replace the base file instead of
+// being deep-merged over it. Profiles still inherit from the profile named by
+// their extends key.
+func WithProfileReplace[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.profileReplace = true
+	}
+}
+
+// SetProfile selects the named profile for this process without persisting
+// it. An empty name restores the default resolution. Call Reload or SoftInit
+// afterwards to load it.
+func (c *ConfigFile[T]) SetProfile(name string) {
+	c.profile = normalizeProfile(name)
+}
+
+// Profile returns the active profile: the one selected with WithProfile or
+// SetProfile, then the <APP>_PROFILE variable, then the persisted marker,
+// and DefaultProfile otherwise.
+func (c *ConfigFile[T]) Profile() string {
+	if c.profile != "" {
+		return c.profile
+	}
+	if name := normalizeProfile(os.Getenv(c.profileEnvName())); name != "" {
+		return name
+	}
+	if buf, err := os.ReadFile(c.profileMarkerPath()); err == nil {
+		if name := normalizeProfile(string(buf)); name != "" {
+			return name
+		}
+	}
+	return DefaultProfile
+}
+
+// Profiles lists the available profiles, DefaultProfile first and the rest
+// sorted by name.
+func (c *ConfigFile[T]) Profiles() ([]string, error) {
+	base := strings.TrimSuffix(c.fileName, filepath.Ext(c.fileName))
+	matches, err := filepath.Glob(filepath.Join(c.DirPath(), base+".*"+filepath.Ext(c.fileName)))
+	if err != nil {
+		return nil, fmt.Errorf("list profiles: %w", err)
+	}
+
+	profiles := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if name, ok := c.profileOf(match); ok && name != DefaultProfile {
+			profiles = append(profiles, name)
+		}
+	}
+	slices.Sort(profiles)
+	return append([]string{DefaultProfile}, profiles...), nil
+}
+
+// ProfilePath returns the file that stores the named profile.
+func (c *ConfigFile[T]) ProfilePath(name string) string {
+	name = normalizeProfile(name)
+	if name == "" || name == DefaultProfile {
+		return filepath.Join(c.DirPath(), c.fileName)
+	}
+	return filepath.Join(c.DirPath(), environmentFileName(c.fileName, name))
+}
+
+// UseProfile persists name as the current profile and loads it. Selecting
+// DefaultProfile removes the marker.
+func (c *ConfigFile[T]) UseProfile(name string) error {
+	if err := c.checkWritable("use profile"); err != nil {
+		return err
+	}
+	name = normalizeProfile(name)
+	if err := c.profileExists(name); err != nil {
+		return err
+	}
+
+	marker := c.profileMarkerPath()
+	if name == DefaultProfile {
+		if err := os.Remove(marker); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("clear current profile: %w", err)
+		}
+	} else if err := fs.WriteFileWithDirs(marker, []byte(name+"\n"), fs.DefaultFileMode); err != nil {
+		return fmt.Errorf("save current profile: %w", err)
+	}
+
+	c.profile = name
+	return c.Reload()
+}
+
+// CreateProfile writes a new, empty profile that inherits every value from
+// the base file. With WithProfileReplace it is initialized with the default
+// data instead.
+func (c *ConfigFile[T]) CreateProfile(name string) error {
+	if err := c.checkWritable("create profile"); err != nil {
+		return err
+	}
+	path, err := c.newProfilePath(name)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	if c.profileReplace {
+		encrypted, err := c.encryptSecrets(c.defaultData)
+		if err != nil {
+			return fmt.Errorf("create profile %q: %w", name, err)
+		}
+		buf, err = c.fileManager.Marshal(encrypted)
+	} else {
+		buf, err = c.fileManager.MarshalDocument(map[string]any{})
+	}
+	if err != nil {
+		return fmt.Errorf("create profile %q: %w", name, err)
+	}
+	if err := fs.WriteFileWithDirs(path, buf, fs.RestrictedFileMode); err != nil {
+		return fmt.Errorf("create profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// CopyProfile creates the profile dst with the on-disk content of src.
+func (c *ConfigFile[T]) CopyProfile(src, dst string) error {
+	if err := c.checkWritable("copy profile"); err != nil {
+		return err
+	}
+	if err := c.profileExists(normalizeProfile(src)); err != nil {
+		return err
+	}
+	path, err := c.newProfilePath(dst)
+	if err != nil {
+		return err
+	}
+
+	buf, err := os.ReadFile(c.ProfilePath(src))
+	if err != nil {
+		return fmt.Errorf("copy profile %q: %w", src, err)
+	}
+	if err := fs.WriteFileWithDirs(path, buf, fs.RestrictedFileMode); err != nil {
+		return fmt.Errorf("copy profile %q: %w", src, err)
+	}
+	return nil
+}
+
+// DeleteProfile removes the named profile. The default profile and the
+// active profile cannot be deleted.
+func (c *ConfigFile[T]) DeleteProfile(name string) error {
+	if err := c.checkWritable("delete profile"); err != nil {
+		return err
+	}
+	name = normalizeProfile(name)
+	if name == DefaultProfile {
+		return fmt.Errorf("delete profile: the %s profile cannot be deleted", DefaultProfile)
+	}
+	if name == c.Profile() {
+		return fmt.Errorf("delete profile: %q is the active profile", name)
+	}
+	if err := c.profileExists(name); err != nil {
+		return err
+	}
+
+	if err := os.Remove(c.ProfilePath(name)); err != nil {
+		return fmt.Errorf("delete profile %q: %w", name, err)
+	}
+	return nil
+}
+
+// checkProfile reports ErrProfileNotFound when a profile other than the
+// default one is active but its file does not exist. Profiles do not apply
+// to an explicit file.
+func (c *ConfigFile[T]) checkProfile() error {
+	if c.file != "" {
+		return nil
+	}
+	if name := c.Profile(); name != DefaultProfile {
+		return c.profileExists(name)
+	}
+	return nil
+}
+
+func (c *ConfigFile[T]) profileExists(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if name == DefaultProfile {
+		return nil
+	}
+
+	exists, err := fs.Exists(c.ProfilePath(name))
+	if err != nil {
+		return fmt.Errorf("check profile %q: %w", name, err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %q (expected %s)", ErrProfileNotFound, name, c.ProfilePath(name))
+	}
+	return nil
+}
+
+func (c *ConfigFile[T]) newProfilePath(name string) (string, error) {
+	name = normalizeProfile(name)
+	if !profileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+
+	path := c.ProfilePath(name)
+	exists, err := fs.Exists(path)
+	if err != nil {
+		return "", fmt.Errorf("check profile %q: %w", name, err)
+	}
+	if exists {
+		return "", fmt.Errorf("%w: %q", ErrProfileExists, name)
+	}
+	return path, nil
+}
+
+// inheritedDocument returns the merged content of the profiles that the
+// profile stored at path inherits from, or nil when there are none or path
+// is not a profile. The extends key is removed from doc and recorded in
+// preserved so it is written back unchanged.
+func (c *ConfigFile[T]) inheritedDocument(path string, doc map[string]any, preserved map[string]preservedValue) (map[string]any, error) {
+	name, ok := c.profileOf(path)
+	if !ok {
+		return nil, nil
+	}
+
+	extends, ok := doc[extendsKey]
+	if ok {
+		preserved[extendsKey] = preservedValue{raw: extends, inFile: true, keep: true}
+		delete(doc, extendsKey)
+	}
+	return c.parentDocument(name, extends, []string{name})
+}
+
+// parentDocument loads the parent of the profile name, which is the profile
+// named by extends or, unless profiles replace the base file, the default
+// profile. chain holds the profiles visited so far to detect cycles.
+func (c *ConfigFile[T]) parentDocument(name string, extends any, chain []string) (map[string]any, error) {
+	var parent string
+	switch v := extends.(type) {
+	case nil:
+		if name == DefaultProfile || c.profileReplace {
+			return nil, nil
+		}
+		parent = DefaultProfile
+	case string:
+		parent = normalizeProfile(v)
+	default:
+		return nil, fmt.Errorf("profile %q: extends must be a profile name", name)
+	}
+
+	if slices.Contains(chain, parent) {
+		return nil, fmt.Errorf("extends cycle: %s -> %s", strings.Join(chain, " -> "), parent)
+	}
+	if err := c.profileExists(parent); err != nil {
+		return nil, fmt.Errorf("profile %q extends %q: %w", name, parent, err)
+	}
+
+	layer, err := c.loadInclude(c.ProfilePath(parent), nil)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q extends %q: %w", name, parent, err)
+	}
+	grandparent := layer[extendsKey]
+	delete(layer, extendsKey)
+
+	inherited, err := c.parentDocument(parent, grandparent, append(chain, parent))
+	if err != nil || inherited == nil {
+		return layer, err
+	}
+	return mergeDocuments(inherited, layer), nil
+}
+
+// profileOf returns the profile stored at path, if path is the base file or
+// a profile file next to it.
+func (c *ConfigFile[T]) profileOf(path string) (string, bool) {
+	if absPath(filepath.Dir(path)) != absPath(c.DirPath()) {
+		return "", false
+	}
+
+	fileName := filepath.Base(path)
+	if fileName == c.fileName {
+		return DefaultProfile, true
+	}
+	ext := filepath.Ext(c.fileName)
+	name, ok := strings.CutPrefix(fileName, strings.TrimSuffix(c.fileName, ext)+".")
+	if !ok {
+		return "", false
+	}
+	if name, ok = strings.CutSuffix(name, ext); !ok || !profileNamePattern.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func (c *ConfigFile[T]) profileEnvName() string {
+	return fmt.Sprintf("%s_PROFILE", strings.ToUpper(c.appName))
+}
+
+func (c *ConfigFile[T]) profileMarkerPath() string {
+	return filepath.Join(c.DirPath(), profileMarkerName)
+}
+
+func normalizeProfile(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// execProviderTimeout bounds how long ExecSecretProvider waits for a command.
+const execProviderTimeout = 30 * time.Second
+
+// secretRefPattern matches ${secret:<provider>:<reference>} placeholders.
+var secretRefPattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_-]+):([^}]*)\}`)
+
+// SecretProvider resolves secret references such as
+// ${secret:file:/run/secrets/db} found in configuration values.
+type SecretProvider interface {
+	// Resolve returns the secret identified by ref.
+	Resolve(ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to the SecretProvider interface.
+type SecretProviderFunc func(ref string) (string, error)
+
+// Resolve calls f(ref).
+func (f SecretProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// FileSecretProvider resolves references by reading the referenced file,
+// without its trailing newline. It is registered as "file" by default.
+func FileSecretProvider() SecretProvider {
+	return SecretProviderFunc(func(ref string) (string, error) {
+		buf, err := os.ReadFile(strings.TrimSpace(ref))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	})
+}
+
+// EnvSecretProvider resolves references by reading the referenced
+// environment variable, which must be set. It is registered as "env" by
+// default.
+func EnvSecretProvider() SecretProvider {
+	return SecretProviderFunc(func(ref string) (string, error) {
+		name := strings.TrimSpace(ref)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	})
+}
+
+// ExecSecretProvider resolves references by running the referenced command
+// line (split on whitespace, without a shell) and using its standard output
+// without the trailing newline. Because it lets configuration files run
+// commands it is not registered by default; enable it as "cmd" with
+// WithExecSecretProvider.
+func ExecSecretProvider() SecretProvider {
+	return SecretProviderFunc(func(ref string) (string, error) {
+		args := strings.Fields(ref)
+		if len(args) == 0 {
+			return "", errors.New("empty command")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), execProviderTimeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("run %s: %w", args[0], err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	})
+}
+
+// WithSecretProvider registers provider under name so values containing
+// ${secret:<name>:<reference>} are resolved when the configuration is
+// loaded. Registering a nil provider removes the name.
+func WithSecretProvider[T Validatable](name string, provider SecretProvider) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(name)
+		if trimmed == "" {
+			return
+		}
+		if provider == nil {
+			delete(c.secretProviders, trimmed)
+			return
+		}
+		c.secretProviders[trimmed] = provider
+	}
+}
+
+// WithExecSecretProvider registers ExecSecretProvider as "cmd", so values
+// such as ${secret:cmd:pass show db} are resolved by running the command.
+// Only enable it when the configuration files are trusted.
+func WithExecSecretProvider[T Validatable]() ConfigFileOption[T] {
+	return WithSecretProvider[T]("cmd", ExecSecretProvider())
+}
+
+// defaultSecretProviders returns the providers registered on every
+// ConfigFile.
+func defaultSecretProviders() map[string]SecretProvider {
+	return map[string]SecretProvider{
+		"file": FileSecretProvider(),
+		"env":  EnvSecretProvider(),
+	}
+}
+
+// resolveSecretRefs replaces every secret reference found in the string
+// values of data. The keys whose values changed are recorded in preserved so
+// the references, not the resolved secrets, are written back to disk.
+func (c *ConfigFile[T]) resolveSecretRefs(data *T, preserved map[string]preservedValue) error {
+	doc, err := c.document(*data)
+	if err != nil {
+		return fmt.Errorf("encode configuration: %w", err)
+	}
+
+	resolved, changed, err := c.resolveValue(doc, "")
+	if err != nil || !changed {
+		return err
+	}
+
+	recordPreserved(preserved, doc, resolved.(map[string]any))
+	*data, err = c.decodeDocument(resolved.(map[string]any))
+	if err != nil {
+		return fmt.Errorf("decode resolved configuration: %w", err)
+	}
+	return nil
+}
+
+// resolveValue returns a copy of value with its secret references resolved
+// and whether anything changed. key is the dotted key used in errors.
+func (c *ConfigFile[T]) resolveValue(value any, key string) (any, bool, error) {
+	switch v := value.(type) {
+	case string:
+		if !secretRefPattern.MatchString(v) {
+			return v, false, nil
+		}
+		var resolveErr error
+		out := secretRefPattern.ReplaceAllStringFunc(v, func(match string) string {
+			groups := secretRefPattern.FindStringSubmatch(match)
+			provider, ok := c.secretProviders[groups[1]]
+			if !ok {
+				resolveErr = errors.Join(resolveErr, fmt.Errorf("%s: unknown secret provider %q", key, groups[1]))
+				return match
+			}
+			secret, err := provider.Resolve(groups[2])
+			if err != nil {
+				resolveErr = errors.Join(resolveErr, fmt.Errorf("%s: resolve secret with %s provider: %w", key, groups[1], err))
+				return match
+			}
+			return secret
+		})
+		return out, true, resolveErr
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		changed := false
+		for name, nested := range v {
+			resolved, nestedChanged, err := c.resolveValue(nested, joinKey(key, name))
+			if err != nil {
+				return nil, false, err
+			}
+			out[name] = resolved
+			changed = changed || nestedChanged
+		}
+		return out, changed, nil
+	case []any:
+		out := make([]any, len(v))
+		changed := false
+		for i, nested := range v {
+			resolved, nestedChanged, err := c.resolveValue(nested, fmt.Sprintf("%s[%d]", key, i))
+			if err != nil {
+				return nil, false, err
+			}
+			out[i] = resolved
+			changed = changed || nestedChanged
+		}
+		return out, changed, nil
+	default:
+		return value, false, nil
+	}
+}
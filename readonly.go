@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned by every operation that would write to disk while
+// the ConfigFile is read-only.
+var ErrReadOnly = errors.New("config: configuration is read-only")
+
+// WithReadOnly makes the ConfigFile never write to disk, for read-only
+// mounts and sandboxes. SoftInit falls back to the defaults in memory when
+// the file does not exist, corrupt files are reported instead of recovered,
+// and every mutating operation returns ErrReadOnly.
+func WithReadOnly[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.readOnly = true
+	}
+}
+
+// ReadOnly reports whether the ConfigFile was built with WithReadOnly.
+func (c *ConfigFile[T]) ReadOnly() bool {
+	return c.readOnly
+}
+
+// checkWritable returns ErrReadOnly, naming op, when the ConfigFile is
+// read-only.
+func (c *ConfigFile[T]) checkWritable(op string) error {
+	if c.readOnly {
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vekio/x/fs"
+)
+
+// quarantineInfix separates the file name from the timestamp of a
+// quarantined file (config.yml.corrupt-<timestamp>).
+const quarantineInfix = ".corrupt-"
+
+var (
+	// ErrCorrupt is returned when the configuration file cannot be parsed.
+	ErrCorrupt = errors.New("config: configuration file is corrupt")
+	// ErrQuarantineNotFound is returned when a quarantined file does not exist.
+	ErrQuarantineNotFound = errors.New("config: quarantined file not found")
+)
+
+// Recovery describes how a corrupt configuration file was recovered. It is
+// reported to the warning handler.
+type Recovery struct {
+	// Quarantined is where the corrupt file was moved.
+	Quarantined string
+	// RestoredFrom is the history snapshot that replaced it, or empty when
+	// the file was regenerated from the defaults.
+	RestoredFrom string
+	// Err is the error that made the file unreadable.
+	Err error
+}
+
+func (r *Recovery) Error() string {
+	source := "the defaults"
+	if r.RestoredFrom != "" {
+		source = r.RestoredFrom
+	}
+	return fmt.Sprintf("recovered corrupt configuration file (%v): moved it to %s and restored %s", r.Err, r.Quarantined, source)
+}
+
+func (r *Recovery) Unwrap() error {
+	return r.Err
+}
+
+// WithRecovery enables automatic recovery from corrupt configuration files.
+// When the file cannot be parsed it is moved aside to
+// <file>.corrupt-<timestamp> and replaced with the newest readable history
+// snapshot, or with the defaults when there is none. What happened is
+// reported to the warning handler as a *Recovery.
+func WithRecovery[T Validatable]() ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.recovery = true
+	}
+}
+
+// Quarantined lists the quarantined copies of the configuration file,
+// newest first.
+func (c *ConfigFile[T]) Quarantined() ([]HistoryEntry, error) {
+	matches, err := filepath.Glob(c.Path() + quarantineInfix + "*")
+	if err != nil {
+		return nil, fmt.Errorf("list quarantined files: %w", err)
+	}
+
+	entries := make([]HistoryEntry, 0, len(matches))
+	for _, match := range matches {
+		id := strings.TrimPrefix(match, c.Path()+quarantineInfix)
+		stamp, err := time.ParseInLocation(historyIDLayout, id, time.Local)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, HistoryEntry{ID: id, Path: match, Time: stamp})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	return entries, nil
+}
+
+// LookupQuarantined looks up a quarantined file by ID. An empty ID selects
+// the newest one.
+func (c *ConfigFile[T]) LookupQuarantined(id string) (HistoryEntry, error) {
+	entries, err := c.Quarantined()
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	for _, entry := range entries {
+		if id == "" || entry.ID == id {
+			return entry, nil
+		}
+	}
+	if id == "" {
+		return HistoryEntry{}, fmt.Errorf("%w: no quarantined files", ErrQuarantineNotFound)
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: %s", ErrQuarantineNotFound, id)
+}
+
+// RedactedQuarantined reads the quarantined file with the given ID (the
+// newest one when id is empty) and masks the values it sets for secret and
+// sensitive settings. As the file is usually corrupt, values are also masked
+// on every line that sets a key named like a sensitive setting.
+func (c *ConfigFile[T]) RedactedQuarantined(id string) ([]byte, error) {
+	entry, err := c.LookupQuarantined(id)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read quarantined file: %w", err)
+	}
+	return c.redactRaw(buf), nil
+}
+
+// RestoreQuarantined moves a quarantined file back in place of the
+// configuration file, which is saved to the history first. The file is not
+// loaded, as it is usually still corrupt; fix it and call Reload.
+func (c *ConfigFile[T]) RestoreQuarantined(id string) error {
+	if err := c.checkWritable("restore quarantined file"); err != nil {
+		return err
+	}
+	entry, err := c.LookupQuarantined(id)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Backup(); err != nil {
+		return err
+	}
+	if err := os.Rename(entry.Path, c.Path()); err != nil {
+		return fmt.Errorf("restore quarantined file: %w", err)
+	}
+	return nil
+}
+
+// recover quarantines the corrupt configuration file and replaces it with
+// the newest readable snapshot or the defaults. cause is the load error.
+func (c *ConfigFile[T]) recover(cause error) error {
+	// The corrupt file is replaced on purpose, which is not a conflict.
+	c.state = nil
+	quarantined := c.Path() + quarantineInfix + time.Now().Format(historyIDLayout)
+	if err := os.Rename(c.Path(), quarantined); err != nil {
+		return fmt.Errorf("quarantine corrupt configuration file: %w", err)
+	}
+	report := &Recovery{Quarantined: quarantined, Err: cause}
+
+	history, err := c.History()
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		buf, err := os.ReadFile(entry.Path)
+		if err != nil || c.verifyLayer(entry.Path, buf) != nil {
+			continue
+		}
+		data, _, err := c.readData(entry.Path, true)
+		if err != nil || c.validate(data, entry.Path) != nil {
+			continue
+		}
+		if err := fs.WriteFileWithDirs(c.Path(), buf, fs.RestrictedFileMode); err != nil {
+			return fmt.Errorf("restore configuration file: %w", err)
+		}
+		if err := c.restoreSignature(entry.Path, buf); err != nil {
+			return err
+		}
+		report.RestoredFrom = entry.Path
+		break
+	}
+	if report.RestoredFrom == "" {
+		if err := c.Init(c.defaultData); err != nil {
+			return err
+		}
+	}
+
+	c.warn(report)
+	return nil
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	// sensitiveTag is the struct tag that marks fields hidden from output.
+	sensitiveTag = "sensitive"
+	// redactedText replaces sensitive values in redacted output.
+	redactedText = "[REDACTED]"
+)
+
+// String hides the secret value from fmt output.
+func (s Secret) String() string {
+	return redactedText
+}
+
+// GoString hides the secret value from %#v output.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret(%q)", redactedText)
+}
+
+// Format implements fmt.Formatter so every verb prints the placeholder
+// instead of the secret value.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", redactedText)
+		return
+	}
+	io.WriteString(f, redactedText)
+}
+
+// LogValue implements slog.LogValuer so secrets are redacted in structured
+// logs.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redactedText)
+}
+
+// Reveal returns the secret value in plain text.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// Redacted returns a copy of the loaded configuration where every Secret
+// value and every field tagged `sensitive:"true"` or `secret:"true"` is
+// masked. Strings are replaced with a placeholder and other values are
+// zeroed. It is safe to print or log.
+func (c *ConfigFile[T]) Redacted() T {
+	return c.redact(c.data)
+}
+
+// redact returns a copy of data with its sensitive values masked.
+func (c *ConfigFile[T]) redact(data T) T {
+	redacted, err := c.clone(data)
+	if err != nil {
+		// Never fall back to the original data: it may contain secrets.
+		return *new(T)
+	}
+	walker := fieldWalker{
+		tag:   c.structTag(),
+		marks: isSensitiveField,
+		visit: func(_ string, value reflect.Value) error {
+			if value.Kind() == reflect.String {
+				if value.Len() > 0 {
+					value.SetString(redactedText)
+				}
+				return nil
+			}
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		},
+	}
+	_ = walker.walk(reflect.ValueOf(&redacted), "", false)
+	return redacted
+}
+
+// RedactedContent reads the configuration file like Content and masks the
+// values it sets for secret and sensitive settings, including those in
+// conditional sections and under former keys. Other files merged into the
+// configuration are not included.
+func (c *ConfigFile[T]) RedactedContent() ([]byte, error) {
+	buf, err := c.Content()
+	if err != nil || len(bytes.TrimSpace(buf)) == 0 {
+		return buf, err
+	}
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		withPath(err, c.Path())
+		return nil, fmt.Errorf("read configuration file: %w", err)
+	}
+	masked, err := c.maskedValues()
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]any)
+	for key, value := range masked {
+		if _, ok := lookupKey(doc, key); ok {
+			set[key] = value
+		}
+	}
+	for _, field := range c.Fields() {
+		value, ok := masked[field.Key]
+		if !ok {
+			continue
+		}
+		for _, alias := range field.Aliases {
+			if _, ok := lookupKey(doc, alias); ok {
+				set[alias] = value
+			}
+		}
+	}
+	if maskSections(doc[matchKey], masked) {
+		set[matchKey] = doc[matchKey]
+	}
+	if len(set) == 0 {
+		return buf, nil
+	}
+	return c.fileManager.PatchDocument(buf, set, nil)
+}
+
+// redactRaw masks the sensitive values set by buf, which does not have to
+// be a valid document. Values of a document that decodes are masked like
+// RedactedContent does; on top of that the value of every line that sets a
+// key named like a sensitive setting or one of its former keys is masked.
+func (c *ConfigFile[T]) redactRaw(buf []byte) []byte {
+	if doc, err := c.fileManager.UnmarshalDocument(buf); err == nil {
+		set := make(map[string]any)
+		for key, value := range c.maskedDocument(doc) {
+			if _, ok := lookupKey(doc, key); ok {
+				set[key] = value
+			}
+		}
+		if patched, err := c.fileManager.PatchDocument(buf, set, nil); err == nil && len(set) > 0 {
+			buf = patched
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, field := range c.Fields() {
+		if !field.Sensitive {
+			continue
+		}
+		for _, key := range append([]string{field.Key}, field.Aliases...) {
+			names[key[strings.LastIndex(key, ".")+1:]] = true
+		}
+	}
+	lines := bytes.Split(buf, []byte("\n"))
+	for i, line := range lines {
+		groups := sensitiveLinePattern.FindSubmatch(line)
+		if groups == nil || !names[string(groups[2])] {
+			continue
+		}
+		if value := string(groups[4]); value == "{" || value == "[" || value == "|" || value == ">" {
+			continue
+		}
+		lines[i] = fmt.Appendf(nil, "%s%s%s%q%s", groups[1], groups[2], groups[3], redactedText, groups[5])
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// sensitiveLinePattern matches a line that sets a scalar value in a JSON or
+// YAML document: the indentation and opening quote, the key, the separator,
+// the value, and a trailing comma.
+var sensitiveLinePattern = regexp.MustCompile(`^(\s*(?:-\s+)?["']?)([\w.-]+)(["']?\s*:\s*)(\S.*?)(\s*,?\s*)$`)
+
+// RedactedEffectiveContent is like EffectiveContent but with sensitive
+// values masked.
+func (c *ConfigFile[T]) RedactedEffectiveContent() ([]byte, error) {
+	buf, err := c.fileManager.Marshal(c.Redacted())
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return buf, nil
+}
+
+// maskedValues returns the redacted form of every setting of the loaded
+// configuration that redaction changes, keyed by dotted path.
+func (c *ConfigFile[T]) maskedValues() (map[string]any, error) {
+	values, err := c.Values()
+	if err != nil {
+		return nil, err
+	}
+	redacted, err := c.RedactedValues()
+	if err != nil {
+		return nil, err
+	}
+	masked := make(map[string]any)
+	for key, value := range values {
+		if redactedValue := maskedValue(redacted, key); !reflect.DeepEqual(value, redactedValue) {
+			masked[key] = redactedValue
+		}
+	}
+	return masked, nil
+}
+
+// maskSections replaces the masked settings in the conditional sections
+// listed under the match key. It reports whether any section sets one.
+func maskSections(raw any, masked map[string]any) bool {
+	sections, _ := raw.([]any)
+	changed := false
+	for _, item := range sections {
+		section, _ := item.(map[string]any)
+		values, ok := section["set"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range masked {
+			if _, ok := lookupKey(values, key); ok {
+				setKey(values, key, value)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// maskedValue returns the value of key in a flattened redacted document, or
+// the placeholder when redaction removed the key.
+func maskedValue(values map[string]any, key string) any {
+	if value, ok := values[key]; ok {
+		return value
+	}
+	return redactedText
+}
+
+// Values returns the loaded configuration as a flat map keyed by dotted
+// paths, exactly as it would be written to disk.
+func (c *ConfigFile[T]) Values() (map[string]any, error) {
+	doc, err := c.document(c.data)
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return flattenDocument(doc), nil
+}
+
+// RedactedValues is like Values but with sensitive values masked.
+func (c *ConfigFile[T]) RedactedValues() (map[string]any, error) {
+	doc, err := c.document(c.Redacted())
+	if err != nil {
+		return nil, fmt.Errorf("encode configuration: %w", err)
+	}
+	return flattenDocument(doc), nil
+}
+
+// RedactError masks every sensitive value of the loaded configuration that
+// appears in the message of err. The original error stays reachable through
+// errors.Is and errors.As.
+func (c *ConfigFile[T]) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return c.redactError(c.data, err)
+}
+
+// redactError masks every sensitive value of data that appears in the
+// message of err.
+func (c *ConfigFile[T]) redactError(data T, err error) error {
+	msg := err.Error()
+	redacted := msg
+	walker := fieldWalker{
+		tag:   c.structTag(),
+		marks: isSensitiveField,
+		visit: func(_ string, value reflect.Value) error {
+			if value.Kind() == reflect.String && value.Len() > 0 {
+				redacted = strings.ReplaceAll(redacted, value.String(), redactedText)
+			}
+			return nil
+		},
+	}
+	_ = walker.walk(reflect.ValueOf(&data), "", false)
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: redacted}
+}
+
+// LogValue implements slog.LogValuer by logging the redacted configuration.
+func (c *ConfigFile[T]) LogValue() slog.Value {
+	return slog.AnyValue(c.Redacted())
+}
+
+// Format implements fmt.Formatter by printing the redacted configuration.
+func (c *ConfigFile[T]) Format(f fmt.State, verb rune) {
+	var flags strings.Builder
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			flags.WriteRune(flag)
+		}
+	}
+	fmt.Fprintf(f, "%"+flags.String()+string(verb), c.Redacted())
+}
+
+// isSensitiveField reports whether a struct field must be hidden from
+// output because it is tagged `sensitive:"true"` or `secret:"true"`.
+func isSensitiveField(field reflect.StructField) bool {
+	return field.Tag.Get(sensitiveTag) == "true" || isSecretField(field)
+}
+
+// redactedError carries a masked message while preserving the wrapped error.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
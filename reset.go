@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/vekio/x/fs/file"
+)
+
+// Exists reports whether the configuration file is present on disk.
+func (c *ConfigFile[T]) Exists() (bool, error) {
+	exists, err := file.Exists(c.Path())
+	if err != nil {
+		return false, fmt.Errorf("check configuration file: %w", err)
+	}
+	return exists, nil
+}
+
+// InitFrom initializes the configuration file with the contents of the
+// template file at path, which must use the same format as the managed file.
+func (c *ConfigFile[T]) InitFrom(path string) error {
+	data, preserved, err := c.readData(path, false)
+	if err != nil {
+		return fmt.Errorf("read template file %s: %w", path, err)
+	}
+	c.preserved = preserved
+	return c.Init(data)
+}
+
+// Reset restores the configuration to the default value configured with
+// WithDefault. When keys are given only those dotted keys are restored and
+// every other value is kept; keys absent from the defaults are removed.
+func (c *ConfigFile[T]) Reset(keys ...string) error {
+	if len(keys) == 0 {
+		return c.Init(c.defaultData)
+	}
+
+	current, err := c.document(c.data)
+	if err != nil {
+		return fmt.Errorf("encode configuration: %w", err)
+	}
+	defaults, err := c.document(c.defaultData)
+	if err != nil {
+		return fmt.Errorf("encode default configuration: %w", err)
+	}
+
+	for _, key := range keys {
+		_, inCurrent := lookupKey(current, key)
+		value, inDefaults := lookupKey(defaults, key)
+		switch {
+		case inDefaults:
+			setKey(current, key, value)
+		case inCurrent:
+			deleteKey(current, key)
+		default:
+			return fmt.Errorf("reset configuration: unknown key %q", key)
+		}
+	}
+
+	data, err := c.decodeDocument(current)
+	if err != nil {
+		return fmt.Errorf("decode configuration: %w", err)
+	}
+	return c.Init(data)
+}
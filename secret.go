@@ -0,0 +1,399 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/vekio/x/fs"
+)
+
+const (
+	// secretPrefix marks values that were encrypted by this package.
+	secretPrefix = "enc:v1:"
+	// secretKeySize is the AES-256 key length in bytes.
+	secretKeySize = 32
+	// secretTag is the struct tag that marks plain string fields as secret.
+	secretTag = "secret"
+)
+
+// ErrNoSecretKey is returned when secret values must be encrypted or
+// decrypted but no key has been configured.
+var ErrNoSecretKey = errors.New("config: no secret key configured")
+
+// Secret is a string configuration value that is encrypted at rest. Plain
+// string fields tagged with `secret:"true"` are treated the same way.
+type Secret string
+
+// WithSecretKey sets the AES-256 key used to encrypt secret fields. The key
+// must be 32 bytes long.
+func WithSecretKey[T Validatable](key []byte) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil || len(key) == 0 {
+			return
+		}
+		c.secretKey = append([]byte(nil), key...)
+	}
+}
+
+// WithSecretKeyFile reads the secret key from path when it is first needed.
+// The file holds the key base64 or hex encoded, or as 32 raw bytes.
+func WithSecretKeyFile[T Validatable](path string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(path)
+		if trimmed == "" {
+			return
+		}
+		c.secretKeyFile = trimmed
+	}
+}
+
+// WithSecretKeyEnv reads the secret key from the named environment variable
+// instead of the default <APP>_SECRET_KEY.
+func WithSecretKeyEnv[T Validatable](name string) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		trimmed := strings.TrimSpace(name)
+		if trimmed == "" {
+			return
+		}
+		c.secretKeyEnv = trimmed
+	}
+}
+
+// GenerateSecretKey returns a new random key suitable for WithSecretKey.
+func GenerateSecretKey() ([]byte, error) {
+	key := make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate secret key: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeSecretKey renders a key in the base64 form accepted by key files and
+// the key environment variable.
+func EncodeSecretKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParseSecretKey decodes a key given base64 or hex encoded, or as raw bytes.
+func ParseSecretKey(raw []byte) ([]byte, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == secretKeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(trimmed); err == nil && len(key) == secretKeySize {
+		return key, nil
+	}
+	if len(raw) == secretKeySize {
+		return append([]byte(nil), raw...), nil
+	}
+	return nil, fmt.Errorf("config: secret key must be %d bytes (raw, base64, or hex encoded)", secretKeySize)
+}
+
+// EncryptSecrets rewrites the configuration file with every secret field
+// encrypted, and encrypts the secrets stored in plain text in the history
+// snapshots too. The file is not saved to the history first, as that would
+// keep a plain text copy.
+func (c *ConfigFile[T]) EncryptSecrets() error {
+	if err := c.save(c.data, false); err != nil {
+		return err
+	}
+	return c.sealHistory(nil)
+}
+
+// DecryptSecrets rewrites the configuration file with every secret field in
+// plain text. Subsequent writes encrypt them again.
+func (c *ConfigFile[T]) DecryptSecrets() error {
+	if err := c.checkWritable("decrypt secrets"); err != nil {
+		return err
+	}
+	return c.writeData(c.data, false)
+}
+
+// RotateSecretKey re-encrypts the configuration file and its history
+// snapshots with newKey, which replaces the configured key for subsequent
+// operations, so the snapshots can still be restored. Secrets in a snapshot
+// that cannot be decrypted with the previous key are left as they are.
+func (c *ConfigFile[T]) RotateSecretKey(newKey []byte) error {
+	if err := c.checkWritable("rotate secret key"); err != nil {
+		return err
+	}
+	if len(newKey) != secretKeySize {
+		return fmt.Errorf("config: secret key must be %d bytes", secretKeySize)
+	}
+	if err := c.Reload(); err != nil {
+		return err
+	}
+	previous, err := c.secretCipher()
+	if err != nil && !errors.Is(err, ErrNoSecretKey) {
+		return err
+	}
+	c.secretKey = append([]byte(nil), newKey...)
+	if err := c.save(c.data, false); err != nil {
+		return err
+	}
+	return c.sealHistory(previous)
+}
+
+// sealHistory encrypts with the current key the secrets that the history
+// snapshots store in plain text, and those encrypted with previous when it
+// is not nil. Values with placeholders or secret references are left alone,
+// and so are snapshots that cannot be decoded.
+func (c *ConfigFile[T]) sealHistory(previous cipher.AEAD) error {
+	history, err := c.History()
+	if err != nil {
+		return err
+	}
+	var aead cipher.AEAD
+	for _, entry := range history {
+		buf, err := os.ReadFile(entry.Path)
+		if err != nil {
+			return fmt.Errorf("read history entry: %w", err)
+		}
+		set, err := c.unsealedSecrets(buf, previous)
+		if err != nil || len(set) == 0 {
+			continue
+		}
+		if aead == nil {
+			if aead, err = c.secretCipher(); err != nil {
+				return fmt.Errorf("encrypt history entry %s: %w", entry.ID, err)
+			}
+		}
+		for key, plain := range set {
+			if set[key], err = sealValue(aead, plain.(string)); err != nil {
+				return fmt.Errorf("encrypt history entry %s: %w", entry.ID, err)
+			}
+		}
+		if buf, err = c.fileManager.PatchDocument(buf, set, nil); err != nil {
+			return fmt.Errorf("encrypt history entry %s: %w", entry.ID, err)
+		}
+		if err := fs.WriteFileWithDirs(entry.Path, buf, fs.RestrictedFileMode); err != nil {
+			return fmt.Errorf("encrypt history entry %s: %w", entry.ID, err)
+		}
+		if c.signingKey != nil {
+			if err := writeSignature(entry.Path, c.signingKey, buf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unsealedSecrets returns the plain text of the secrets in buf, an encoded
+// document, that must be encrypted with the current key: those in plain text
+// and those encrypted with previous, when it is not nil.
+func (c *ConfigFile[T]) unsealedSecrets(buf []byte, previous cipher.AEAD) (map[string]any, error) {
+	doc, err := c.fileManager.UnmarshalDocument(buf)
+	if err != nil {
+		return nil, err
+	}
+	expanded := doc
+	if c.interpolate {
+		interpolated, err := interpolateEnv(buf)
+		if err != nil {
+			return nil, err
+		}
+		if expanded, err = c.fileManager.UnmarshalDocument(interpolated); err != nil {
+			return nil, err
+		}
+	}
+	data, err := c.decodeDocument(withoutDirectives(expanded))
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]any)
+	err = walkSecrets(reflect.ValueOf(&data), c.structTag(), func(key string, _ reflect.Value) error {
+		raw, _ := lookupKey(doc, key)
+		value, ok := raw.(string)
+		if !ok || value == "" || secretRefPattern.MatchString(value) {
+			return nil
+		}
+		if expandedValue, _ := lookupKey(expanded, key); expandedValue != raw {
+			return nil
+		}
+		encoded, encrypted := strings.CutPrefix(value, secretPrefix)
+		switch {
+		case !encrypted:
+			set[key] = value
+		case previous != nil:
+			if plain, err := openValue(previous, encoded); err == nil {
+				set[key] = plain
+			}
+		}
+		return nil
+	})
+	return set, err
+}
+
+// resolveSecretKey returns the configured key, reading the key file or the
+// key environment variable the first time it is needed.
+func (c *ConfigFile[T]) resolveSecretKey() ([]byte, error) {
+	if len(c.secretKey) > 0 {
+		return c.secretKey, nil
+	}
+
+	if c.secretKeyFile != "" {
+		raw, err := os.ReadFile(c.secretKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file: %w", err)
+		}
+		key, err := ParseSecretKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		c.secretKey = key
+		return key, nil
+	}
+
+	envName := c.secretKeyEnv
+	if envName == "" {
+		envName = fmt.Sprintf("%s_SECRET_KEY", strings.ToUpper(c.appName))
+	}
+	if raw := os.Getenv(envName); raw != "" {
+		key, err := ParseSecretKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envName, err)
+		}
+		c.secretKey = key
+		return key, nil
+	}
+
+	return nil, ErrNoSecretKey
+}
+
+// encryptSecrets returns a copy of data whose secret fields are encrypted.
+func (c *ConfigFile[T]) encryptSecrets(data T) (T, error) {
+	encrypted, err := c.clone(data)
+	if err != nil {
+		return data, err
+	}
+
+	var aead cipher.AEAD
+	err = walkSecrets(reflect.ValueOf(&encrypted), c.structTag(), func(key string, value reflect.Value) error {
+		plain := value.String()
+		if plain == "" || strings.HasPrefix(plain, secretPrefix) {
+			return nil
+		}
+		// Values resolved from a reference are written back as the
+		// reference, so there is nothing to encrypt.
+		if preserved, ok := c.preserved[key]; ok && preserved.loaded == plain {
+			return nil
+		}
+		if aead == nil {
+			if aead, err = c.secretCipher(); err != nil {
+				return err
+			}
+		}
+		sealed, err := sealValue(aead, plain)
+		if err != nil {
+			return err
+		}
+		value.SetString(sealed)
+		return nil
+	})
+	if err != nil {
+		return data, fmt.Errorf("encrypt secrets: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decryptSecrets decrypts every encrypted secret field of data in place.
+// Values stored in plain text are left untouched.
+func (c *ConfigFile[T]) decryptSecrets(data *T) error {
+	var aead cipher.AEAD
+	err := walkSecrets(reflect.ValueOf(data), c.structTag(), func(_ string, value reflect.Value) error {
+		encoded, ok := strings.CutPrefix(value.String(), secretPrefix)
+		if !ok {
+			return nil
+		}
+		if aead == nil {
+			var err error
+			if aead, err = c.secretCipher(); err != nil {
+				return err
+			}
+		}
+		plain, err := openValue(aead, encoded)
+		if err != nil {
+			return err
+		}
+		value.SetString(plain)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("decrypt secrets: %w", err)
+	}
+	return nil
+}
+
+// sealValue encrypts plain with aead into the form secrets are stored in.
+func sealValue(aead cipher.AEAD, plain string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openValue decrypts encoded, a stored secret without its prefix, with aead.
+func openValue(aead cipher.AEAD, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plain), nil
+}
+
+func (c *ConfigFile[T]) secretCipher() (cipher.AEAD, error) {
+	key, err := c.resolveSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// isSecretField reports whether a struct field is tagged `secret:"true"`.
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get(secretTag) == "true"
+}
+
+// walkSecrets calls fn for every settable string value reachable from v that
+// is either of type Secret or declared by a field tagged `secret:"true"`,
+// together with its dotted document key.
+func walkSecrets(v reflect.Value, tag string, fn func(key string, value reflect.Value) error) error {
+	walker := fieldWalker{
+		tag:   tag,
+		marks: isSecretField,
+		visit: func(key string, value reflect.Value) error {
+			if value.Kind() != reflect.String {
+				return nil
+			}
+			return fn(key, value)
+		},
+	}
+	return walker.walk(v, "", false)
+}
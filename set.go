@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Set changes the setting with the given dotted key, as reported by Fields,
+// and writes the configuration file. It fails with ErrLocked when the policy
+// file locks the key.
+func (c *ConfigFile[T]) Set(key string, value any) error {
+	var field *Field
+	for _, candidate := range c.Fields() {
+		if candidate.Key == key {
+			field = &candidate
+			break
+		}
+	}
+	if field == nil {
+		return fmt.Errorf("set %q: unknown key", key)
+	}
+	if !assignable(reflect.TypeOf(value), field.Type) {
+		return fmt.Errorf("set %q: cannot use %T as %s", key, value, field.Type)
+	}
+	locked, err := c.IsLocked(key)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return fmt.Errorf("set %q: %w", key, ErrLocked)
+	}
+
+	data := c.data
+	target := reflect.ValueOf(&data).Elem().FieldByIndex(field.index)
+	target.Set(reflect.ValueOf(value).Convert(target.Type()))
+	return c.Init(data)
+}
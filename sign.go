@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vekio/x/fs"
+)
+
+// signatureSuffix is appended to the configuration file name to form the
+// detached signature file (config.yml.sig).
+const signatureSuffix = ".sig"
+
+var (
+	// ErrNoSignature is returned when a signature is required but the
+	// signature file does not exist.
+	ErrNoSignature = errors.New("config: configuration file is not signed")
+	// ErrBadSignature is returned when the signature does not match the
+	// configuration file for any of the trusted keys.
+	ErrBadSignature = errors.New("config: configuration signature is invalid")
+	// ErrNoTrustedKeys is returned by Verify when no trusted keys are
+	// configured.
+	ErrNoTrustedKeys = errors.New("config: no trusted keys configured")
+)
+
+// WithTrustedKeys makes Reload and SoftInit verify the Ed25519 signature of
+// the configuration file, and of every file merged into it, against keys
+// before using them. That includes the system, parent profile, included,
+// drop-in, discovered, and policy files; sign them with SignFile. Files
+// without a valid signature from one of the keys are rejected with
+// ErrNoSignature or ErrBadSignature.
+func WithTrustedKeys[T Validatable](keys ...ed25519.PublicKey) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		for _, key := range keys {
+			if len(key) == ed25519.PublicKeySize {
+				c.trustedKeys = append(c.trustedKeys, key)
+			}
+		}
+	}
+}
+
+// WithSigningKey signs the configuration file with key every time it is
+// written, so files verified with WithTrustedKeys stay valid after changes.
+func WithSigningKey[T Validatable](key ed25519.PrivateKey) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil || len(key) != ed25519.PrivateKeySize {
+			return
+		}
+		c.signingKey = key
+	}
+}
+
+// GenerateSigningKey returns a new Ed25519 key pair for signing
+// configuration files.
+func GenerateSigningKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate signing key: %w", err)
+	}
+	return public, private, nil
+}
+
+// EncodeSigningKey renders an Ed25519 public or private key in the base64
+// form accepted by ParsePublicKey and ParsePrivateKey.
+func EncodeSigningKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey decodes an Ed25519 public key given base64 or hex encoded.
+func ParsePublicKey(raw []byte) (ed25519.PublicKey, error) {
+	key, err := parseSigningKey(raw, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("config: public key %w", err)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// ParsePrivateKey decodes an Ed25519 private key given base64 or hex
+// encoded, either as the 64-byte key or as its 32-byte seed.
+func ParsePrivateKey(raw []byte) (ed25519.PrivateKey, error) {
+	if seed, err := parseSigningKey(raw, ed25519.SeedSize); err == nil {
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	key, err := parseSigningKey(raw, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("config: private key %w", err)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// SignaturePath returns the detached signature file of the configuration
+// file: the file's path followed by .sig.
+func (c *ConfigFile[T]) SignaturePath() string {
+	return c.Path() + signatureSuffix
+}
+
+// Sign signs the current content of the configuration file with key and
+// writes the signature file.
+func (c *ConfigFile[T]) Sign(key ed25519.PrivateKey) error {
+	if err := c.checkWritable("sign configuration file"); err != nil {
+		return err
+	}
+	return SignFile(c.Path(), key)
+}
+
+// SignFile signs the current content of the file at path with key and writes
+// the signature next to it, with a .sig suffix. Use it for the files merged
+// into a configuration file verified with WithTrustedKeys.
+func SignFile(path string, key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("config: signing key must be %d bytes", ed25519.PrivateKeySize)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read file to sign: %w", err)
+	}
+	return writeSignature(path, key, buf)
+}
+
+// Verify checks the signature of the configuration file against the trusted
+// keys configured with WithTrustedKeys.
+func (c *ConfigFile[T]) Verify() error {
+	return c.VerifyWith(c.trustedKeys...)
+}
+
+// VerifyWith checks the signature of the configuration file against keys. It
+// succeeds when any of them produced the signature.
+func (c *ConfigFile[T]) VerifyWith(keys ...ed25519.PublicKey) error {
+	if len(keys) == 0 {
+		return ErrNoTrustedKeys
+	}
+	buf, err := c.Content()
+	if err != nil {
+		return err
+	}
+	return verifySignature(c.Path(), buf, keys)
+}
+
+// signWritten signs buf, the content just written to the configuration
+// file, when a signing key is configured.
+func (c *ConfigFile[T]) signWritten(buf []byte) error {
+	if c.signingKey == nil {
+		return nil
+	}
+	return writeSignature(c.Path(), c.signingKey, buf)
+}
+
+// restoreSignature signs buf, the content just restored into the
+// configuration file from src, when a signing key is configured, and
+// otherwise restores the signature of src with it.
+func (c *ConfigFile[T]) restoreSignature(src string, buf []byte) error {
+	if c.signingKey != nil {
+		return c.signWritten(buf)
+	}
+	return copySignature(src, c.Path())
+}
+
+// copySignature copies the signature file of src to dst, or removes the
+// signature file of dst when src has none.
+func copySignature(src, dst string) error {
+	buf, err := os.ReadFile(src + signatureSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.Remove(dst + signatureSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove signature file: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read signature file: %w", err)
+	}
+	if err := fs.WriteFileWithDirs(dst+signatureSuffix, buf, fs.DefaultFileMode); err != nil {
+		return fmt.Errorf("write signature file: %w", err)
+	}
+	return nil
+}
+
+// verifyLayer checks buf, the content of the file at path, against its
+// signature file when trusted keys are configured.
+func (c *ConfigFile[T]) verifyLayer(path string, buf []byte) error {
+	if len(c.trustedKeys) == 0 {
+		return nil
+	}
+	return verifySignature(path, buf, c.trustedKeys)
+}
+
+// writeSignature signs buf, the content of the file at path, with key and
+// writes the signature file.
+func writeSignature(path string, key ed25519.PrivateKey, buf []byte) error {
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, buf))
+	if err := fs.WriteFileWithDirs(path+signatureSuffix, []byte(signature+"\n"), fs.DefaultFileMode); err != nil {
+		return fmt.Errorf("write signature file: %w", err)
+	}
+	return nil
+}
+
+// verifySignature checks buf, the content of the file at path, against its
+// signature file with keys.
+func verifySignature(path string, buf []byte, keys []ed25519.PublicKey) error {
+	raw, err := os.ReadFile(path + signatureSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", path, ErrNoSignature)
+	}
+	if err != nil {
+		return fmt.Errorf("read signature file: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%s: %w: malformed signature file", path, ErrBadSignature)
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, buf, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", path, ErrBadSignature)
+}
+
+// parseSigningKey decodes a base64 or hex encoded key of the given size.
+func parseSigningKey(raw []byte, size int) ([]byte, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == size {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(trimmed); err == nil && len(key) == size {
+		return key, nil
+	}
+	return nil, fmt.Errorf("must be %d bytes (base64 or hex encoded)", size)
+}
@@ -6,14 +6,6 @@ import (
 	"strings"
 )
 
-func defaultConfigPath() string {
-	dir, err := os.UserConfigDir()
-	if err != nil {
-		dir = os.TempDir()
-	}
-	return dir
-}
-
 func defaultAppName() string {
 	if len(os.Args) == 0 {
 		return "app"
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ValidationPolicy controls what happens when configuration data fails its
+// Validate method while being loaded or written.
+type ValidationPolicy int
+
+const (
+	// ValidationStrict rejects invalid data: loads fail and keep the
+	// previous data, and writes fail without touching the file.
+	ValidationStrict ValidationPolicy = iota
+	// ValidationWarnOnly accepts invalid data and reports the failure to the
+	// warning handler.
+	ValidationWarnOnly
+	// ValidationOff never calls Validate automatically.
+	ValidationOff
+)
+
+// ValidationError reports that configuration data failed its Validate method.
+type ValidationError struct {
+	// Path is the file the data was loaded from or was about to be written to.
+	Path string
+	// Err is the error returned by Validate.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: invalid configuration: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// WithValidationPolicy sets how validation failures are handled on load and
+// write. The default is ValidationStrict.
+func WithValidationPolicy[T Validatable](policy ValidationPolicy) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.validationPolicy = policy
+	}
+}
+
+// WithWarningHandler sets the function that receives non-fatal problems,
+// such as validation failures under ValidationWarnOnly. By default they are
+// logged with slog at warning level.
+func WithWarningHandler[T Validatable](handler func(error)) ConfigFileOption[T] {
+	return func(c *ConfigFile[T]) {
+		if c == nil {
+			return
+		}
+		c.warningHandler = handler
+	}
+}
+
+// validate runs the Validate method of data according to the validation
+// policy. path names the file the data belongs to.
+func (c *ConfigFile[T]) validate(data T, path string) error {
+	if c.validationPolicy == ValidationOff {
+		return nil
+	}
+	err := data.Validate()
+	if err == nil {
+		return nil
+	}
+
+	verr := &ValidationError{Path: path, Err: c.redactError(data, err)}
+	if c.validationPolicy == ValidationWarnOnly {
+		c.warn(verr)
+		return nil
+	}
+	return verr
+}
+
+// warn reports a non-fatal problem to the warning handler.
+func (c *ConfigFile[T]) warn(err error) {
+	if c.warningHandler != nil {
+		c.warningHandler(err)
+		return
+	}
+	slog.Warn("config: "+err.Error(), "app", c.appName)
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/vekio/x/fs"
+)
+
+// defaultSystemConfigDirs is used when $XDG_CONFIG_DIRS is unset or empty.
+const defaultSystemConfigDirs = "/etc/xdg"
+
+// ErrNoUserDir is returned when a per-user base directory cannot be
+// determined, typically because neither the XDG variable nor $HOME is set.
+var ErrNoUserDir = errors.New("config: cannot determine the user base directory")
+
+// ConfigDir returns the directory holding appName's configuration files:
+// $XDG_CONFIG_HOME/<appName>, or the platform default when unset.
+func ConfigDir(appName string) (string, error) {
+	base, err := userConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// DataDir returns the directory for appName's persistent data files:
+// $XDG_DATA_HOME/<appName>, or ~/.local/share/<appName> when unset.
+func DataDir(appName string) (string, error) {
+	return userDir("XDG_DATA_HOME", filepath.Join(".local", "share"), appName)
+}
+
+// CacheDir returns the directory for appName's cache files:
+// $XDG_CACHE_HOME/<appName>, or the platform cache directory when unset.
+func CacheDir(appName string) (string, error) {
+	if base, ok := xdgEnv("XDG_CACHE_HOME"); ok {
+		return filepath.Join(base, appName), nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoUserDir, err)
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// StateDir returns the directory for appName's state files such as logs and
+// history: $XDG_STATE_HOME/<appName>, or ~/.local/state/<appName> when unset.
+func StateDir(appName string) (string, error) {
+	return userDir("XDG_STATE_HOME", filepath.Join(".local", "state"), appName)
+}
+
+// SystemConfigDirs returns the system-wide configuration directories from
+// $XDG_CONFIG_DIRS (default /etc/xdg), most important first.
+func SystemConfigDirs() []string {
+	value := os.Getenv("XDG_CONFIG_DIRS")
+	if strings.TrimSpace(value) == "" {
+		value = defaultSystemConfigDirs
+	}
+
+	dirs := make([]string, 0)
+	for _, dir := range filepath.SplitList(value) {
+		if dir = strings.TrimSpace(dir); filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// DataDir returns the data directory for the configured application.
+func (c *ConfigFile[T]) DataDir() (string, error) {
+	return DataDir(c.appName)
+}
+
+// CacheDir returns the cache directory for the configured application.
+func (c *ConfigFile[T]) CacheDir() (string, error) {
+	return CacheDir(c.appName)
+}
+
+// StateDir returns the state directory for the configured application.
+func (c *ConfigFile[T]) StateDir() (string, error) {
+	return StateDir(c.appName)
+}
+
+// SystemConfigPaths returns the read-only system configuration files that
+// exist for this application, most important first. Their values act as
+// defaults below the user's file.
+func (c *ConfigFile[T]) SystemConfigPaths() ([]string, error) {
+	paths := make([]string, 0)
+	for _, dir := range SystemConfigDirs() {
+		candidate := filepath.Join(dir, c.appName, c.fileName)
+		exists, err := fs.Exists(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("check system configuration: %w", err)
+		}
+		if exists && candidate != c.Path() {
+			paths = append(paths, candidate)
+		}
+	}
+	return paths, nil
+}
+
+// systemDocument merges the system configuration files, least important
+// first. It returns nil when there are none.
+func (c *ConfigFile[T]) systemDocument() (map[string]any, error) {
+	paths, err := c.SystemConfigPaths()
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	merged := map[string]any{}
+	for _, path := range slices.Backward(paths) {
+		layer, err := c.loadInclude(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeDocuments(merged, layer)
+	}
+	return merged, nil
+}
+
+// defaultConfigPath returns the base directory for user configuration:
+// $XDG_CONFIG_HOME when set, or the platform default.
+func defaultConfigPath() (string, error) {
+	return userConfigHome()
+}
+
+func userConfigHome() (string, error) {
+	if base, ok := xdgEnv("XDG_CONFIG_HOME"); ok {
+		return base, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoUserDir, err)
+	}
+	return base, nil
+}
+
+// userDir resolves an XDG base directory that os does not provide. On macOS
+// and Windows the platform's application data directory is used instead of
+// the home-relative fallback.
+func userDir(envName, homeRelative, appName string) (string, error) {
+	if base, ok := xdgEnv(envName); ok {
+		return filepath.Join(base, appName), nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrNoUserDir, err)
+		}
+		return filepath.Join(base, appName), nil
+	case "windows":
+		if base := os.Getenv("LocalAppData"); base != "" {
+			return filepath.Join(base, appName), nil
+		}
+		return "", fmt.Errorf("%w: %%LocalAppData%% is not set", ErrNoUserDir)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoUserDir, err)
+	}
+	return filepath.Join(home, homeRelative, appName), nil
+}
+
+// xdgEnv returns the value of an XDG variable. Relative paths are invalid
+// per the specification and ignored.
+func xdgEnv(name string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" || !filepath.IsAbs(value) {
+		return "", false
+	}
+	return value, true
+}
+
+// initFromSystem creates the configuration file from the defaults layered
+// under the system configuration. Keys provided by the system files are
+// left out of the new file so they keep following those files.
+func (c *ConfigFile[T]) initFromSystem() error {
+	system, err := c.systemDocument()
+	if err != nil {
+		return fmt.Errorf("init configuration file: %w", err)
+	}
+	if system == nil {
+		return c.Init(c.defaultData)
+	}
+
+	defaults, err := c.document(c.defaultData)
+	if err != nil {
+		return fmt.Errorf("init configuration file: %w", err)
+	}
+	data, err := c.decodeDocument(mergeDocuments(defaults, system))
+	if err != nil {
+		return fmt.Errorf("init configuration file: %w", err)
+	}
+
+	c.preserved = make(map[string]preservedValue)
+	if err := c.keepOut(system, data, c.preserved); err != nil {
+		return fmt.Errorf("init configuration file: %w", err)
+	}
+	return c.Init(data)
+}
+
+// keepOut records in preserved the keys set by layer, a document merged
+// under the configuration file, so they are not written to the file as long
+// as data keeps their loaded values. Keys already recorded are left alone.
+func (c *ConfigFile[T]) keepOut(layer map[string]any, data T, preserved map[string]preservedValue) error {
+	loaded, err := c.document(data)
+	if err != nil {
+		return err
+	}
+	flat := flattenDocument(loaded)
+	for key := range flattenDocument(layer) {
+		if _, ok := preserved[key]; ok {
+			continue
+		}
+		if value, ok := flat[key]; ok {
+			preserved[key] = preservedValue{loaded: value}
+		}
+	}
+	return nil
+}
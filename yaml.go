@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/vekio/x/fs"
 	"gopkg.in/yaml.v3"
@@ -23,23 +24,25 @@ func (b *YAMLFileManager[T]) Extension() string {
 }
 
 // LoadDataFromFile reads the YAML file, unmarshals it into the provided value,
-// and returns an error if the file cannot be read or parsed.
+// and returns an error if the file cannot be read or a *ParseError if it
+// cannot be parsed.
 func (b *YAMLFileManager[T]) LoadDataFromFile(filePath string, data *T) error {
 	buf, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("read YAML file: %w", err)
 	}
-	if err := yaml.Unmarshal(buf, data); err != nil {
-		return fmt.Errorf("error unmarshaling YAML data: %w", err)
+	if err := b.Unmarshal(buf, data); err != nil {
+		withPath(err, filePath)
+		return err
 	}
 	return nil
 }
 
 // WriteDataToFile serializes the value as YAML and persists it to disk.
 func (b *YAMLFileManager[T]) WriteDataToFile(filePath string, data T) error {
-	buf, err := yaml.Marshal(data)
+	buf, err := b.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("error marshaling YAML data: %w", err)
+		return err
 	}
 	// Ensure the destination exists and persists the encoded payload.
 	if err := fs.WriteFileWithDirs(filePath, buf, fs.RestrictedFileMode); err != nil {
@@ -47,3 +50,125 @@ func (b *YAMLFileManager[T]) WriteDataToFile(filePath string, data T) error {
 	}
 	return nil
 }
+
+// Marshal encodes the value as YAML.
+func (b *YAMLFileManager[T]) Marshal(data T) ([]byte, error) {
+	buf, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling YAML data: %w", err)
+	}
+	return buf, nil
+}
+
+// Unmarshal decodes a YAML payload into the provided value. Decoding errors
+// are returned as a *ParseError.
+func (b *YAMLFileManager[T]) Unmarshal(buf []byte, data *T) error {
+	if err := yaml.Unmarshal(buf, data); err != nil {
+		return newYAMLParseError(err)
+	}
+	return nil
+}
+
+// UnmarshalDocument decodes a YAML mapping into a generic document.
+func (b *YAMLFileManager[T]) UnmarshalDocument(buf []byte) (map[string]any, error) {
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, newYAMLParseError(err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	return doc, nil
+}
+
+// MarshalDocument encodes a generic document as YAML.
+func (b *YAMLFileManager[T]) MarshalDocument(doc map[string]any) ([]byte, error) {
+	buf, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling YAML document: %w", err)
+	}
+	return buf, nil
+}
+
+// PatchDocument rewrites the YAML mapping in buf, replacing the value stored
+// under each dotted key in set and deleting the dotted keys in remove. Keys
+// keep their original order (and comments) and new keys are appended.
+func (b *YAMLFileManager[T]) PatchDocument(buf []byte, set map[string]any, remove []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, newYAMLParseError(err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("error patching YAML document: expected a mapping")
+	}
+
+	for key, value := range set {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return nil, fmt.Errorf("error marshaling YAML value for %s: %w", key, err)
+		}
+		setYAMLKey(root, strings.Split(key, "."), &node)
+	}
+	for _, key := range remove {
+		removeYAMLKey(root, strings.Split(key, "."))
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling YAML document: %w", err)
+	}
+	return out, nil
+}
+
+// yamlMappingValue returns the index of the value node stored under key in
+// a mapping node, or -1.
+func yamlMappingValue(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func setYAMLKey(mapping *yaml.Node, path []string, value *yaml.Node) {
+	index := yamlMappingValue(mapping, path[0])
+	if index < 0 {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+		valueNode := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		mapping.Content = append(mapping.Content, keyNode, valueNode)
+		index = len(mapping.Content) - 1
+	}
+
+	if len(path) == 1 {
+		// Keep comments attached to the previous value.
+		value.HeadComment = mapping.Content[index].HeadComment
+		value.LineComment = mapping.Content[index].LineComment
+		value.FootComment = mapping.Content[index].FootComment
+		mapping.Content[index] = value
+		return
+	}
+
+	if mapping.Content[index].Kind != yaml.MappingNode {
+		mapping.Content[index] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	setYAMLKey(mapping.Content[index], path[1:], value)
+}
+
+func removeYAMLKey(mapping *yaml.Node, path []string) {
+	index := yamlMappingValue(mapping, path[0])
+	if index < 0 {
+		return
+	}
+	if len(path) > 1 {
+		if mapping.Content[index].Kind == yaml.MappingNode {
+			removeYAMLKey(mapping.Content[index], path[1:])
+		}
+		return
+	}
+	mapping.Content = append(mapping.Content[:index-1], mapping.Content[index+1:]...)
+}